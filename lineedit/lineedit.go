@@ -0,0 +1,431 @@
+// Package lineedit reads lines of input from a terminal, with cursor
+// movement, history, reverse search and tab completion. When input is not
+// a terminal, lines are read as they are, without editing.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupt is returned by ReadLine when Ctrl+c is pressed
+var ErrInterrupt = errors.New("interrupted")
+
+// Most lines kept in the history
+const historySize = 1000
+
+// Editor reads lines from the user
+type Editor struct {
+	// Complete lists the completions of the word before the cursor. When nil,
+	// Tab is inserted like any other key.
+	Complete func(word string) []string
+
+	term        *os.File
+	in          *bufio.Reader
+	out         io.Writer
+	history     []string
+	historyFile string
+	// A key to be read again, after it ended a search
+	unread rune
+}
+
+// New returns an Editor that reads from in and echoes to out. Lines are
+// edited when term, the file in reads from, is a terminal.
+func New(term *os.File, in *bufio.Reader, out io.Writer) *Editor {
+	return &Editor{term: term, in: in, out: out}
+}
+
+// Terminal reports whether lines are read from a terminal and edited
+func (ed *Editor) Terminal() bool {
+	return ed.term != nil && IsTerminal(ed.term)
+}
+
+// IsTerminal reports whether f is a terminal
+func IsTerminal(f *os.File) bool {
+	_, err := getTermios(f.Fd())
+	return err == nil
+}
+
+// LoadHistory reads the history from path, which later lines are also
+// added to. A missing file is an empty history.
+func (ed *Editor) LoadHistory(path string) error {
+	ed.historyFile = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			ed.history = append(ed.history, line)
+		}
+	}
+	if len(ed.history) > historySize {
+		ed.history = ed.history[len(ed.history)-historySize:]
+	}
+	return nil
+}
+
+// AddHistory adds line to the history, and to the history file if there is
+// one. Blank lines and repeats of the last line are skipped.
+func (ed *Editor) AddHistory(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(ed.history); n > 0 && ed.history[n-1] == line {
+		return nil
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > historySize {
+		ed.history = ed.history[1:]
+	}
+	if ed.historyFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(ed.historyFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadLine prints prompt and returns the line entered, without its line
+// ending. It returns io.EOF at the end of input, and ErrInterrupt when the
+// line is abandoned with Ctrl+c.
+func (ed *Editor) ReadLine(prompt string) (string, error) {
+	if ed.term == nil {
+		return ed.readPlain(prompt)
+	}
+	restore, err := makeRaw(ed.term.Fd())
+	if err != nil {
+		return ed.readPlain(prompt)
+	}
+	defer restore()
+	return ed.edit(prompt)
+}
+
+// readPlain reads a line without editing it
+func (ed *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(ed.out, prompt)
+	line, err := ed.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Keys read by edit
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// Keys of escape sequences, given codes past those of runes
+const (
+	keyUp = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyForwardDelete
+)
+
+// readKey reads a rune typed, or the key of an escape sequence
+func (ed *Editor) readKey() (rune, error) {
+	if r := ed.unread; r != 0 {
+		ed.unread = 0
+		return r, nil
+	}
+	r, _, err := ed.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+	next, _, err := ed.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if next != '[' && next != 'O' {
+		return keyEscape, nil
+	}
+	code, _, err := ed.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	// Sequences such as ESC [ 3 ~ carry a number
+	var num string
+	for code >= '0' && code <= '9' || code == ';' {
+		num += string(code)
+		if code, _, err = ed.in.ReadRune(); err != nil {
+			return 0, err
+		}
+	}
+	switch code {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch num {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyForwardDelete, nil
+		}
+	}
+	return keyEscape, nil
+}
+
+// line is the state of the line being edited
+type line struct {
+	buf []rune
+	pos int
+}
+
+func (l *line) insert(rs ...rune) {
+	l.buf = append(l.buf[:l.pos], append(rs, l.buf[l.pos:]...)...)
+	l.pos += len(rs)
+}
+
+func (l *line) set(s string) {
+	l.buf = []rune(s)
+	l.pos = len(l.buf)
+}
+
+// edit reads a line in raw mode, where every key is seen and the line is
+// echoed by the editor
+func (ed *Editor) edit(prompt string) (string, error) {
+	l := &line{}
+	// Lines of the history, with the line being entered last
+	hist := append(append([]string(nil), ed.history...), "")
+	h := len(hist) - 1
+	ed.refresh(prompt, l)
+	for {
+		key, err := ed.readKey()
+		if err != nil {
+			fmt.Fprint(ed.out, "\r\n")
+			if err == io.EOF && len(l.buf) > 0 {
+				return string(l.buf), nil
+			}
+			return "", err
+		}
+		switch key {
+		case keyEnter, '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(l.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", ErrInterrupt
+		case keyCtrlD:
+			if len(l.buf) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+			l.deleteForward()
+		case keyForwardDelete:
+			l.deleteForward()
+		case keyBackspace, keyDelete:
+			if l.pos > 0 {
+				l.pos--
+				l.deleteForward()
+			}
+		case keyLeft, keyCtrlB:
+			if l.pos > 0 {
+				l.pos--
+			}
+		case keyRight, keyCtrlF:
+			if l.pos < len(l.buf) {
+				l.pos++
+			}
+		case keyHome, keyCtrlA:
+			l.pos = 0
+		case keyEnd, keyCtrlE:
+			l.pos = len(l.buf)
+		case keyCtrlK:
+			l.buf = l.buf[:l.pos]
+		case keyCtrlU:
+			l.buf = l.buf[l.pos:]
+			l.pos = 0
+		case keyCtrlW:
+			// Delete back to the space before the previous word
+			start := l.pos
+			for start > 0 && l.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && l.buf[start-1] != ' ' {
+				start--
+			}
+			l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+			l.pos = start
+		case keyCtrlL:
+			fmt.Fprint(ed.out, "\x1b[H\x1b[2J")
+		case keyUp, keyCtrlP, keyDown, keyCtrlN:
+			// Keep changes to the line being entered when moving away
+			hist[h] = string(l.buf)
+			if (key == keyUp || key == keyCtrlP) && h > 0 {
+				h--
+			} else if (key == keyDown || key == keyCtrlN) && h < len(hist)-1 {
+				h++
+			}
+			l.set(hist[h])
+		case keyCtrlR:
+			done, err := ed.search(prompt, l)
+			if done || err != nil {
+				return string(l.buf), err
+			}
+		case keyTab:
+			if ed.Complete == nil {
+				l.insert(key)
+			} else {
+				ed.complete(l)
+			}
+		case keyEscape, keyCtrlG:
+		default:
+			if unicode.IsPrint(key) {
+				l.insert(key)
+			}
+		}
+		ed.refresh(prompt, l)
+	}
+}
+
+func (l *line) deleteForward() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// refresh redraws the prompt and line, and places the cursor
+func (ed *Editor) refresh(prompt string, l *line) {
+	col := len([]rune(prompt)) + l.pos
+	fmt.Fprintf(ed.out, "\r%s%s\x1b[0K\r", prompt, string(l.buf))
+	if col > 0 {
+		fmt.Fprintf(ed.out, "\x1b[%dC", col)
+	}
+}
+
+// search looks back through the history for lines containing what is
+// typed, as Ctrl+r does in a shell. It reports true when Enter accepted the
+// match as the line entered.
+func (ed *Editor) search(prompt string, l *line) (bool, error) {
+	var query []rune
+	original := string(l.buf)
+	// Index of the history line matched
+	found := len(ed.history)
+	match := func(from int) {
+		if from >= len(ed.history) {
+			from = len(ed.history) - 1
+		}
+		for i := from; i >= 0; i-- {
+			if strings.Contains(ed.history[i], string(query)) {
+				found = i
+				l.set(ed.history[i])
+				return
+			}
+		}
+	}
+	for {
+		fmt.Fprintf(ed.out, "\r(reverse-i-search)`%s': %s\x1b[0K", string(query), string(l.buf))
+		key, err := ed.readKey()
+		if err != nil {
+			return true, err
+		}
+		switch key {
+		case keyCtrlR:
+			match(found - 1)
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				found = len(ed.history)
+				match(found - 1)
+			}
+		case keyEnter, '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return true, nil
+		case keyCtrlC, keyCtrlG:
+			l.set(original)
+			return false, nil
+		default:
+			if !unicode.IsPrint(key) {
+				// Any other key ends the search, and edits the match
+				ed.unread = key
+				return false, nil
+			}
+			query = append(query, key)
+			match(found)
+		}
+	}
+}
+
+// Runes that end a word to be completed
+const wordBreaks = " \t()[]{}\"'`,;"
+
+func wordStart(buf []rune, pos int) int {
+	for pos > 0 && !strings.ContainsRune(wordBreaks, buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// complete completes the word before the cursor as far as its completions
+// agree, or lists them when they already do
+func (ed *Editor) complete(l *line) {
+	start := wordStart(l.buf, l.pos)
+	word := string(l.buf[start:l.pos])
+	completions := ed.Complete(word)
+	if len(completions) == 0 {
+		return
+	}
+	prefix := completions[0]
+	for _, c := range completions[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(completions) == 1 {
+		prefix += " "
+	}
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		l.insert([]rune(prefix[len(word):])...)
+		return
+	}
+	fmt.Fprintf(ed.out, "\r\n%s\r\n", strings.Join(completions, "  "))
+}
@@ -0,0 +1,121 @@
+package lineedit
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	cases := []struct {
+		keys string
+		want string
+	}{
+		{"(+ 1 2)\r", "(+ 1 2)"},
+		{"+ 1 2\x1b[D\x1b[D\x1b[D3 \r", "+ 3 1 2"},
+		{"+ 1 22\x7f\r", "+ 1 2"},
+		{"1 2\x01+ \x05 3\r", "+ 1 2 3"},
+		{"1 2\x1b[H+ \x1b[F 3\r", "+ 1 2 3"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"abc\x1b[D\x1b[D\x04\r", "ac"},
+		{"abc def\x02\x02\x0b\r", "abc d"},
+		{"abc def\x02\x02\x15\r", "ef"},
+		{"def {x} 10\x17\x17\r", "def "},
+		// History, newest first
+		{"\x1b[A\r", "+ 1 2"},
+		{"\x1b[A\x1b[A\r", "print \"2\""},
+		{"\x10\x10\x10\x10\x0e\r", "print \"2\""},
+		{"new\x1b[A\x1b[B\r", "new"},
+		// Reverse search
+		{"\x12def\r", "def {x} 1"},
+		{"\x122\x12\r", "print \"2\""},
+		{"\x12x\x1b[D\x7f\r", "def {x}1"},
+		{"ab\x12zzz\x07c\r", "abc"},
+		// Completion
+		{"(pr\t\r", "(print "},
+		{"(de\t\r", "(def"},
+		{"(xyz\t\r", "(xyz"},
+		{"(def\t\r", "(def"},
+	}
+
+	history := []string{"def {x} 1", "print \"2\"", "+ 1 2"}
+	for _, c := range cases {
+		ed := New(nil, bufio.NewReader(strings.NewReader(c.keys)), io.Discard)
+		ed.history = history
+		ed.Complete = func(word string) []string {
+			var names []string
+			for _, name := range []string{"def", "defmacro", "print"} {
+				if strings.HasPrefix(name, word) {
+					names = append(names, name)
+				}
+			}
+			return names
+		}
+		got, err := ed.edit("lispy> ")
+		if err != nil || got != c.want {
+			t.Errorf("edit keys: %q returned: %q, %v, actually expected: %q", c.keys, got, err, c.want)
+		}
+	}
+}
+
+func TestEditEnds(t *testing.T) {
+	cases := []struct {
+		keys string
+		want error
+	}{
+		{"", io.EOF},
+		{"\x04", io.EOF},
+		{"abc\x03", ErrInterrupt},
+	}
+
+	for _, c := range cases {
+		ed := New(nil, bufio.NewReader(strings.NewReader(c.keys)), io.Discard)
+		if _, err := ed.edit("lispy> "); err != c.want {
+			t.Errorf("edit keys: %q returned: %v, actually expected: %v", c.keys, err, c.want)
+		}
+	}
+}
+
+func TestReadLinePlain(t *testing.T) {
+	var out strings.Builder
+	ed := New(nil, bufio.NewReader(strings.NewReader("+ 1 2\r\nlast")), &out)
+	for _, want := range []string{"+ 1 2", "last"} {
+		if got, err := ed.ReadLine("lispy> "); err != nil || got != want {
+			t.Errorf("ReadLine returned: %q, %v, actually expected: %q", got, err, want)
+		}
+	}
+	if _, err := ed.ReadLine("lispy> "); err != io.EOF {
+		t.Errorf("ReadLine at the end of input returned: %v, actually expected: %v", err, io.EOF)
+	}
+	if out.String() != "lispy> lispy> lispy> " {
+		t.Errorf("ReadLine wrote: %q, actually expected three prompts", out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	ed := New(nil, nil, io.Discard)
+	if err := ed.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory of a missing file returned: %v", err)
+	}
+	for _, line := range []string{"+ 1 2", "", "  ", "+ 1 2", "def {x} 1"} {
+		if err := ed.AddHistory(line); err != nil {
+			t.Fatalf("AddHistory returned: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "+ 1 2\ndef {x} 1\n" {
+		t.Errorf("History file holds: %q, %v, actually expected: %q", data, err, "+ 1 2\ndef {x} 1\n")
+	}
+
+	ed = New(nil, nil, io.Discard)
+	if err := ed.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory returned: %v", err)
+	}
+	if strings.Join(ed.history, "|") != "+ 1 2|def {x} 1" {
+		t.Errorf("LoadHistory read: %q, actually expected the lines added before", ed.history)
+	}
+}
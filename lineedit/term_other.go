@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package lineedit
+
+import "errors"
+
+var errNoTerminal = errors.New("terminal editing is not supported on this platform")
+
+func getTermios(fd uintptr) (struct{}, error) {
+	return struct{}{}, errNoTerminal
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errNoTerminal
+}
@@ -1,15 +1,16 @@
 package lispy
 
 import "fmt"
-import "math"
-import "github.com/sunzenshen/go-build-your-own-lisp/mpc"
+import "io"
+import "math/big"
+import "strings"
 
 type lbuiltin func(*lenv, *lval) *lval
 
 func builtinOp(e *lenv, a *lval, op string) *lval {
 	// Ensure all arguments are numbers
 	for _, cell := range a.cells {
-		if cell.ltype != lvalNumType {
+		if !cell.isNumber() {
 			return lvalErr("Cannot operate on non-number: %s", cell.ltypeName())
 		}
 	}
@@ -17,34 +18,27 @@ func builtinOp(e *lenv, a *lval, op string) *lval {
 	x := a.lvalPop(0)
 	// Handle unary negation
 	if op == "-" && a.cellCount() == 0 {
-		x.num = -x.num
+		x = lvalNeg(x)
 	}
 	// Process remaining elements
+	st := e.lenvState()
 	for a.cellCount() > 0 {
-		// Pop the next element
+		// Pop the next element and perform symbol's operation
 		y := a.lvalPop(0)
-		// Perform symbol's operation
-		if op == "+" {
-			x.num += y.num
-		} else if op == "-" {
-			x.num -= y.num
-		} else if op == "*" {
-			x.num *= y.num
-		} else if op == "^" {
-			x.num = int64(math.Pow(float64(x.num), float64(y.num)))
-		} else if op == "/" {
-			if y.num == 0 {
-				x = lvalErr("Division By Zero!")
-			} else {
-				x.num /= y.num
-			}
-		} else if op == "%" {
-			if y.num == 0 {
-				x = lvalErr("Modulus By Zero!")
-			} else {
-				x.num %= y.num
+		// Powers certain to be too large are caught before they are worked
+		// out, as they can take too long to build at all
+		if op == "^" {
+			if err := st.lstateNumber(lvalPowBits(x, y)); err != nil {
+				return err
 			}
 		}
+		x = lvalArith(op, x, y)
+		if x.ltype == lvalErrType {
+			break
+		}
+		if err := st.lstateNumber(uint64(lvalNumBits(x))); err != nil {
+			return err
+		}
 	}
 	return x
 }
@@ -57,6 +51,10 @@ func builtinPut(e *lenv, a *lval) *lval {
 	return builtinVar(e, a, "=")
 }
 
+func builtinSet(e *lenv, a *lval) *lval {
+	return builtinVar(e, a, "set!")
+}
+
 func builtinVar(e *lenv, a *lval, function string) *lval {
 	if a.cells[0].ltype != lvalQexprType {
 		return lvalErr("Function %s passed incorrect type: %s", function, a.cells[0].ltypeName())
@@ -83,6 +81,12 @@ func builtinVar(e *lenv, a *lval, function string) *lval {
 		if function == "=" {
 			e.lenvPut(cell, a.cells[i+1])
 		}
+		// 'set!' to change the nearest existing binding
+		if function == "set!" {
+			if x := e.lenvSet(cell, a.cells[i+1]); x.ltype == lvalErrType {
+				return x
+			}
+		}
 	}
 	return lvalSexpr()
 }
@@ -99,11 +103,7 @@ func builtinHead(e *lenv, a *lval) *lval {
 		return lvalErr("Function 'head' passed {}!")
 	}
 	// Otherwise, get the head
-	v := a.lvalTake(0)
-	for v.cellCount() > 1 {
-		v.lvalPop(1)
-	}
-	return v
+	return lvalSlice(lvalQexprType, a.cells[0].cells[:1])
 }
 
 func builtinTail(e *lenv, a *lval) *lval {
@@ -117,13 +117,14 @@ func builtinTail(e *lenv, a *lval) *lval {
 	if a.cells[0].cellCount() == 0 {
 		return lvalErr("Function 'tail' passed {}!")
 	}
-	// Otherwise, get the tail
-	v := a.lvalTake(0)
-	v.lvalPop(0)
-	return v
+	// Otherwise, get the tail, which shares the cells of the list
+	return lvalSlice(lvalQexprType, a.cells[0].cells[1:])
 }
 
 func builtinList(e *lenv, a *lval) *lval {
+	if err := e.lenvState().lstateAlloc(a.cellCount(), 0); err != nil {
+		return err
+	}
 	a.ltype = lvalQexprType
 	return a
 }
@@ -135,9 +136,7 @@ func builtinEval(e *lenv, a *lval) *lval {
 	if a.cells[0].ltype != lvalQexprType {
 		return lvalErr("Function 'eval' passed incorrect type: %s", a.lvalString())
 	}
-	x := a.lvalTake(0)
-	x.ltype = lvalSexprType
-	return x.lvalEval(e)
+	return lvalThunk(lvalSexprOf(a.cells[0]), e)
 }
 
 func builtinJoin(e *lenv, a *lval) *lval {
@@ -146,9 +145,16 @@ func builtinJoin(e *lenv, a *lval) *lval {
 			return lvalErr("Function 'join' passed incorrect type: %s", a.lvalString())
 		}
 	}
-	x := a.lvalPop(0)
-	for a.cellCount() > 0 {
-		x = lvalJoin(x, a.lvalPop(0))
+	cells := 0
+	for _, cell := range a.cells {
+		cells += cell.cellCount()
+	}
+	if err := e.lenvState().lstateAlloc(cells, 0); err != nil {
+		return err
+	}
+	x := lvalSlice(a.cells[0].ltype, make([]*lval, 0, cells))
+	for _, cell := range a.cells {
+		x.cells = append(x.cells, cell.cells...)
 	}
 	return x
 }
@@ -164,13 +170,95 @@ func builtinLambda(e *lenv, a *lval) *lval {
 	// Check that the first Q-expression contains only Symbols
 	for _, cell := range a.cells[0].cells {
 		if cell.ltype != lvalSymType {
-			return lvalErr("Cannot define non-symbol. Got type %s instead", cell.ltype)
+			return lvalErr("Cannot define non-symbol. Got type %s instead", cell.ltypeName())
 		}
 	}
 	// Pop first 2 arguments and pass them to lvalLambda
 	formals := a.lvalPop(0)
 	body := a.lvalPop(0)
-	return lvalLambda(formals, body)
+	return lvalLambda(e, formals, body)
+}
+
+func builtinDefmacro(e *lenv, a *lval) *lval {
+	if a.cellCount() != 2 {
+		return lvalErr("defmacro has %d arguments, not 2 as expected", a.cellCount())
+	}
+	if a.cells[0].ltype != lvalQexprType || a.cells[1].ltype != lvalQexprType {
+		return lvalErr("defmacro passed incorrect types: %s", a.lvalString())
+	}
+	if a.cells[0].cellCount() == 0 {
+		return lvalErr("defmacro passed {} for its name and arguments")
+	}
+	// Check that the first Q-expression contains only Symbols
+	for _, cell := range a.cells[0].cells {
+		if cell.ltype != lvalSymType {
+			return lvalErr("Cannot define non-symbol. Got type %s instead", cell.ltypeName())
+		}
+	}
+	// First symbol names the macro, the rest are its formals
+	name := a.cells[0].cells[0]
+	formals := lvalSlice(lvalQexprType, a.cells[0].cells[1:])
+	m := lvalLambda(e, formals, a.cells[1])
+	m.macro = true
+	e.lenvDef(name, m)
+	return lvalSexpr()
+}
+
+func builtinQuasiquote(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("quasiquote passed in %d args, not 1", a.cellCount())
+	}
+	return lvalQuasiquote(e, a.lvalPop(0))
+}
+
+// lvalQuasiquote fills in a template, evaluating the parts marked with
+// unquote and splicing in the lists marked with unquote-splicing
+func lvalQuasiquote(e *lenv, x *lval) *lval {
+	if x.ltype != lvalSexprType && x.ltype != lvalQexprType {
+		return x
+	}
+	if x.isQuoteForm("unquote") {
+		return x.cells[1].lvalEval(e)
+	}
+	// The template is filled into a new list, so it can be used again
+	y := lvalSlice(x.ltype, make([]*lval, 0, x.cellCount()))
+	y.pos = x.pos
+	for _, cell := range x.cells {
+		if cell.isQuoteForm("unquote-splicing") {
+			z := cell.cells[1].lvalEval(e)
+			if z.ltype == lvalErrType {
+				return z
+			}
+			if z.ltype != lvalQexprType && z.ltype != lvalSexprType {
+				return lvalErr("unquote-splicing passed non-list: %s", z.ltypeName()).lvalAt(cell.pos)
+			}
+			y.cells = append(y.cells, z.cells...)
+			continue
+		}
+		z := lvalQuasiquote(e, cell)
+		if z.ltype == lvalErrType {
+			return z
+		}
+		y.cells = append(y.cells, z)
+	}
+	if err := e.lenvState().lstateAlloc(y.cellCount(), 0); err != nil {
+		return err
+	}
+	return y
+}
+
+// isQuoteForm reports whether x is an S-expression of the form (name arg)
+func (x *lval) isQuoteForm(name string) bool {
+	return x.ltype == lvalSexprType && x.cellCount() == 2 &&
+		x.cells[0].ltype == lvalSymType && x.cells[0].sym == name
+}
+
+func builtinUnquote(e *lenv, a *lval) *lval {
+	return lvalErr("unquote used outside of quasiquote")
+}
+
+func builtinUnquoteSplicing(e *lenv, a *lval) *lval {
+	return lvalErr("unquote-splicing used outside of quasiquote")
 }
 
 func builtinGreaterThan(e *lenv, a *lval) *lval {
@@ -193,27 +281,24 @@ func builtinOrd(e *lenv, a *lval, op string) *lval {
 	if a.cellCount() != 2 {
 		return lvalErr("%s passed in with %d cells not 2", op, a.cellCount())
 	}
-	if a.cells[0].ltype != lvalNumType {
+	if !a.cells[0].isNumber() {
 		return lvalErr("%s cell0 is not a number, but type %s", op, a.cells[0].ltypeName())
 	}
-	if a.cells[1].ltype != lvalNumType {
+	if !a.cells[1].isNumber() {
 		return lvalErr("%s cell1 is not a number, but type %s", op, a.cells[1].ltypeName())
 	}
 	var cmp bool
+	c := lvalNumCmp(a.cells[0], a.cells[1])
 	if op == ">" {
-		cmp = a.cells[0].num > a.cells[1].num
+		cmp = c > 0
 	} else if op == "<" {
-		cmp = a.cells[0].num < a.cells[1].num
+		cmp = c < 0
 	} else if op == ">=" {
-		cmp = a.cells[0].num >= a.cells[1].num
+		cmp = c >= 0
 	} else if op == "<=" {
-		cmp = a.cells[0].num <= a.cells[1].num
-	}
-	// 0 = false, and everything else is true
-	if cmp {
-		return lvalNum(1)
+		cmp = c <= 0
 	}
-	return lvalNum(0)
+	return lvalBool(cmp)
 }
 
 func builtinCmp(e *lenv, a *lval, op string) *lval {
@@ -226,10 +311,7 @@ func builtinCmp(e *lenv, a *lval, op string) *lval {
 	} else if op == "!=" {
 		cmp = !lvalEq(a.cells[0], a.cells[1])
 	}
-	if cmp {
-		return lvalNum(1)
-	}
-	return lvalNum(0)
+	return lvalBool(cmp)
 }
 
 func builtinEqual(e *lenv, a *lval) *lval {
@@ -244,28 +326,82 @@ func builtinIf(e *lenv, a *lval) *lval {
 	if a.cellCount() != 3 {
 		return lvalErr("if passed in %d args, not 3", a.cellCount())
 	}
-	if a.cells[0].ltype != lvalNumType {
-		return lvalErr("if cell0 is not a number")
-	}
 	if a.cells[1].ltype != lvalQexprType {
 		return lvalErr("if cell1 is not a Q-exp")
 	}
 	if a.cells[2].ltype != lvalQexprType {
 		return lvalErr("if cell2 is not a Q-exp")
 	}
-	// Mark both expressions as evaluable
-	a.cells[1].ltype = lvalSexprType
-	a.cells[2].ltype = lvalSexprType
-	// Determine branch direction
-	var x *lval
-	if a.cells[0].num != 0 {
+	// Determine branch direction, evaluated in tail position
+	if lvalTruthy(a.cells[0]) {
 		// If condition is true, evaluate the first expression
-		x = a.lvalPop(1).lvalEval(e)
-	} else {
-		// Otherwise, evaluate the second expression
-		x = a.lvalPop(2).lvalEval(e)
+		return lvalThunk(lvalSexprOf(a.cells[1]), e)
 	}
-	return x
+	// Otherwise, evaluate the second expression
+	return lvalThunk(lvalSexprOf(a.cells[2]), e)
+}
+
+// builtinCase evaluates its first argument once, then the key of each clause
+// in turn until one is equal to it. The rest of that clause is evaluated in
+// tail position, in the caller's scope.
+func builtinCase(e *lenv, a *lval) *lval {
+	if a.cellCount() == 0 {
+		return lvalErr("case passed no value to match")
+	}
+	x := a.lvalPop(0).lvalEval(e)
+	if x.ltype == lvalErrType {
+		return x
+	}
+	for _, clause := range a.cells {
+		if clause.ltype != lvalQexprType || clause.cellCount() == 0 {
+			return lvalErr("case clause is not a Q-exp starting with a key: %s", clause.lvalString())
+		}
+		key := clause.cells[0].lvalEval(e)
+		if key.ltype == lvalErrType {
+			return key
+		}
+		if lvalEq(x, key) {
+			body := lvalSlice(lvalSexprType, clause.cells[1:])
+			body.pos = clause.pos
+			return lvalThunk(body, e)
+		}
+	}
+	return lvalErr("No case found!")
+}
+
+func builtinNot(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("not passed in %d args, not 1", a.cellCount())
+	}
+	return lvalBool(!lvalTruthy(a.cells[0]))
+}
+
+func builtinAnd(e *lenv, a *lval) *lval {
+	return builtinLogic(e, a, "and")
+}
+
+func builtinOr(e *lenv, a *lval) *lval {
+	return builtinLogic(e, a, "or")
+}
+
+// builtinLogic is the special form behind 'and' and 'or'. Arguments are
+// evaluated in order until one decides the result, which is returned as is.
+func builtinLogic(e *lenv, a *lval, op string) *lval {
+	// With nothing to decide, 'and' is true and 'or' is false
+	if a.cellCount() == 0 {
+		return lvalBool(op == "and")
+	}
+	for a.cellCount() > 1 {
+		x := a.lvalPop(0).lvalEval(e)
+		if x.ltype == lvalErrType {
+			return x
+		}
+		if lvalTruthy(x) != (op == "and") {
+			return x
+		}
+	}
+	// The last argument is in tail position
+	return lvalThunk(a.lvalPop(0), e)
 }
 
 func builtinLoad(e *lenv, a *lval) *lval {
@@ -275,45 +411,60 @@ func builtinLoad(e *lenv, a *lval) *lval {
 	if a.cells[0].ltype != lvalStrType {
 		return lvalErr("load did not get a string for input")
 	}
-	if e.parser == nil {
-		return lvalErr("load env is missing parser")
-	}
 	// Parse string as a file name
-	var ret *lval
-	r, err := mpc.ParseContents(a.cells[0].str, e.parser)
+	contents, err := e.lenvState().lstateReadFile(a.cells[0].str)
 	if err != nil {
-		// Get parse error in string format
-		errMsg := mpc.GetErrorStr(r)
-		ret = lvalErr("Could not load library %s", errMsg)
-	} else if r != nil {
-		// Read contents
-		expr := lvalRead(mpc.GetOutput(r))
-		mpc.DeleteAstPtr(r)
-		// Evaluate each expression
-		for expr.cellCount() > 0 {
-			x := expr.lvalPop(0).lvalEval(e)
-			// If evaluation leads to an error, print it
-			if x.ltype == lvalErrType {
-				x.lvalPrintLn()
-			}
-		}
-		// Return an empty list
-		ret = lvalSexpr()
+		return lvalErr("Could not load library %s", err)
 	}
+	expr, err := lvalReadString(a.cells[0].str, string(contents))
+	if err != nil {
+		return lvalErr("Could not load library %s", err)
+	}
+	return lvalEvalAll(e, expr)
+}
 
-	return ret
+// lvalEvalAll evaluates each expression read from a file in turn, stopping
+// at the first error
+func lvalEvalAll(e *lenv, expr *lval) *lval {
+	for expr.cellCount() > 0 {
+		x := expr.lvalPop(0).lvalEval(e)
+		if x.ltype == lvalErrType {
+			return x
+		}
+	}
+	// Return an empty list
+	return lvalSexpr()
 }
 
 func builtinPrint(e *lenv, a *lval) *lval {
+	w := e.lenvState().stdout
 	// Print each argument followed by a space
 	for _, cell := range a.cells {
-		cell.lvalPrint()
-		fmt.Println(" ")
+		cell.lvalPrint(w)
+		fmt.Fprint(w, " ")
 	}
-	fmt.Println("")
+	fmt.Fprintln(w, "")
 	return lvalSexpr()
 }
 
+func builtinReadLine(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 || a.cells[0].ltype != lvalSexprType || a.cells[0].cellCount() != 0 {
+		return lvalErr("read-line passed %s, expected ()", a.lvalString())
+	}
+	line, err := e.lenvState().stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return lvalErr("read-line reached the end of input")
+		}
+		return lvalErr("read-line failed: %s", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if err := e.lenvState().lstateAlloc(0, len(line)); err != nil {
+		return err
+	}
+	return lvalStr(line)
+}
+
 func builtinError(e *lenv, a *lval) *lval {
 	if a.cellCount() != 1 {
 		return lvalErr("error: %d parameters were passed in, expected only 1", a.cellCount())
@@ -322,7 +473,129 @@ func builtinError(e *lenv, a *lval) *lval {
 		return lvalErr("error: argument is not a string")
 	}
 	// Construct error from the first argument
-	return lvalErr(a.cells[0].str)
+	return lvalErr("%s", a.cells[0].str)
+}
+
+func builtinThrow(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("throw passed in %d args, not 1", a.cellCount())
+	}
+	x := a.lvalPop(0)
+	// A caught error is raised again as it was
+	if x.ltype == lvalCondType {
+		x = lvalCopy(x)
+		x.ltype = lvalErrType
+		return x
+	}
+	return lvalThrown(x)
+}
+
+// builtinTry evaluates its first argument, handing any error it raises to
+// the clauses that follow:
+//
+//	try {body} {catch e handler...} {finally cleanup...}
+//
+// The handler is evaluated with e bound to the error as a condition, and
+// its result is that of the try. The cleanup is evaluated afterwards
+// whether or not there was an error. Evaluation that is stopped from
+// outside, by cancellation or limits, cannot be caught.
+func builtinTry(e *lenv, a *lval) *lval {
+	if a.cellCount() < 2 {
+		return lvalErr("try passed in %d args, expected a body and catch or finally clauses", a.cellCount())
+	}
+	for _, cell := range a.cells {
+		if cell.ltype != lvalQexprType {
+			return lvalErr("Function 'try' passed incorrect type: %s", cell.ltypeName())
+		}
+	}
+	body := lvalSexprOf(a.cells[0])
+	var name, handler, cleanup *lval
+	for _, clause := range a.cells[1:] {
+		if clause.cellCount() == 0 || clause.cells[0].ltype != lvalSymType {
+			return lvalErr("try clause does not start with catch or finally: %s", clause.lvalString())
+		}
+		rest := lvalSlice(lvalSexprType, clause.cells[1:])
+		rest.pos = clause.pos
+		switch clause.cells[0].sym {
+		case "catch":
+			if rest.cellCount() == 0 || rest.cells[0].ltype != lvalSymType {
+				return lvalErr("catch is not followed by a symbol: %s", lvalSlice(lvalQexprType, rest.cells).lvalString())
+			}
+			if rest.cellCount() == 1 {
+				return lvalErr("catch is not followed by a handler: %s", clause.lvalString())
+			}
+			name = rest.cells[0]
+			handler = lvalSlice(lvalSexprType, rest.cells[1:])
+			handler.pos = clause.pos
+		case "finally":
+			cleanup = rest
+		default:
+			return lvalErr("try clause does not start with catch or finally: %s", clause.lvalString())
+		}
+	}
+	x := body.lvalEval(e)
+	if x.ltype == lvalErrType && x.cause == nil && handler != nil {
+		// Bind the error in a scope of the handler's own
+		env := lenvNew()
+		env.par = e
+		env.lenvPut(name, lvalCondition(x))
+		if cleanup == nil {
+			return lvalThunk(handler, env)
+		}
+		x = handler.lvalEval(env)
+	}
+	if cleanup != nil {
+		// An error while cleaning up takes the place of the result
+		if y := cleanup.lvalEval(e); y.ltype == lvalErrType {
+			return y
+		}
+	}
+	return x
+}
+
+func builtinIsError(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("error? passed in %d args, not 1", a.cellCount())
+	}
+	return lvalBool(a.cells[0].ltype == lvalCondType)
+}
+
+func builtinErrorMessage(e *lenv, a *lval) *lval {
+	return builtinCondition(e, a, "error-message")
+}
+
+func builtinErrorValue(e *lenv, a *lval) *lval {
+	return builtinCondition(e, a, "error-value")
+}
+
+func builtinCondition(e *lenv, a *lval, function string) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("%s passed in %d args, not 1", function, a.cellCount())
+	}
+	if a.cells[0].ltype != lvalCondType {
+		return lvalErr("%s passed non-error: %s", function, a.cells[0].ltypeName())
+	}
+	c := a.cells[0]
+	// Errors that were not thrown carry their message as their value
+	if function == "error-message" || c.thrown == nil {
+		if err := e.lenvState().lstateAlloc(0, len(c.err)); err != nil {
+			return err
+		}
+		return lvalStr(c.err)
+	}
+	return c.thrown
+}
+
+func builtinExit(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("exit passed in %d args, not 1", a.cellCount())
+	}
+	x := a.cells[0]
+	if x.ltype != lvalNumType || x.bnum != nil || x.num < 0 || x.num > 255 {
+		return lvalErr("exit passed %s, expected a status from 0 to 255", x.lvalString())
+	}
+	// Stop evaluation, rather than the process, for the host to decide
+	return lvalStop(&ExitError{int(x.num)}, "Exited with status %d", x.num)
 }
 
 func builtinAdd(e *lenv, a *lval) *lval {
@@ -348,3 +621,156 @@ func builtinMod(e *lenv, a *lval) *lval {
 func builtinPow(e *lenv, a *lval) *lval {
 	return builtinOp(e, a, "^")
 }
+
+func builtinNumerator(e *lenv, a *lval) *lval {
+	return builtinRatPart(e, a, "numerator")
+}
+
+func builtinDenominator(e *lenv, a *lval) *lval {
+	return builtinRatPart(e, a, "denominator")
+}
+
+func builtinRatPart(e *lenv, a *lval, function string) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("%s passed in %d args, not 1", function, a.cellCount())
+	}
+	if a.cells[0].ltype != lvalNumType && a.cells[0].ltype != lvalRatType {
+		return lvalErr("%s passed non-exact number: %s", function, a.cells[0].ltypeName())
+	}
+	r := a.cells[0].toRat()
+	if function == "numerator" {
+		return lvalBigNum(new(big.Int).Set(r.Num()))
+	}
+	return lvalBigNum(new(big.Int).Set(r.Denom()))
+}
+
+func builtinExactToInexact(e *lenv, a *lval) *lval {
+	if a.cellCount() != 1 {
+		return lvalErr("exact->inexact passed in %d args, not 1", a.cellCount())
+	}
+	if !a.cells[0].isNumber() {
+		return lvalErr("exact->inexact passed non-number: %s", a.cells[0].ltypeName())
+	}
+	return lvalFloat(a.cells[0].toFloat())
+}
+
+func builtinHashMap(e *lenv, a *lval) *lval {
+	if a.cellCount()%2 != 0 {
+		return lvalErr("Function 'hash-map' needs an even number of arguments, got %d", a.cellCount())
+	}
+	if err := e.lenvState().lstateAlloc(a.cellCount(), 0); err != nil {
+		return err
+	}
+	return lvalMapAssoc(lvalMap(), a)
+}
+
+// lvalMapAssoc puts each key and value pair of a into m
+func lvalMapAssoc(m *lval, a *lval) *lval {
+	for i := 0; i < a.cellCount(); i += 2 {
+		if x := m.lvalMapPut(a.cells[i], a.cells[i+1]); x.ltype == lvalErrType {
+			return x
+		}
+	}
+	return m
+}
+
+// builtinMapArgs checks the map passed as first argument of function, and
+// the count of the arguments that follow it
+func builtinMapArgs(a *lval, function string, min int) *lval {
+	if a.cellCount() < min+1 {
+		return lvalErr("Function '%s' passed too few arguments: %s", function, a.lvalString())
+	}
+	if a.cells[0].ltype != lvalMapType {
+		return lvalErr("Function '%s' passed incorrect type: %s", function, a.cells[0].ltypeName())
+	}
+	if a.cells[0].entries == nil {
+		return lvalErr("Function '%s' passed a map literal that has not been evaluated: %s",
+			function, a.cells[0].lvalString())
+	}
+	return nil
+}
+
+func builtinGet(e *lenv, a *lval) *lval {
+	if err := builtinMapArgs(a, "get", 1); err != nil {
+		return err
+	}
+	if a.cellCount() > 3 {
+		return lvalErr("Function 'get' passed too many arguments: %s", a.lvalString())
+	}
+	v := a.cells[0].lvalMapGet(a.cells[1])
+	if v != nil {
+		return v
+	}
+	// Fall back to the default value, if one is given
+	if a.cellCount() == 3 {
+		return a.lvalPop(2)
+	}
+	return lvalErr("Key not found in map: %s", a.cells[1].lvalString())
+}
+
+func builtinAssoc(e *lenv, a *lval) *lval {
+	if err := builtinMapArgs(a, "assoc", 2); err != nil {
+		return err
+	}
+	if a.cellCount()%2 != 1 {
+		return lvalErr("Function 'assoc' needs a value for each key: %s", a.lvalString())
+	}
+	m := lvalMapCopy(a.lvalPop(0))
+	if err := e.lenvState().lstateAlloc(a.cellCount(), 0); err != nil {
+		return err
+	}
+	return lvalMapAssoc(m, a)
+}
+
+func builtinDissoc(e *lenv, a *lval) *lval {
+	if err := builtinMapArgs(a, "dissoc", 0); err != nil {
+		return err
+	}
+	m := lvalMapCopy(a.lvalPop(0))
+	for _, cell := range a.cells {
+		if k, ok := lvalMapKey(cell); ok {
+			delete(m.entries, k)
+		}
+	}
+	return m
+}
+
+func builtinKeys(e *lenv, a *lval) *lval {
+	return builtinMapList(e, a, "keys")
+}
+
+func builtinValues(e *lenv, a *lval) *lval {
+	return builtinMapList(e, a, "values")
+}
+
+// builtinMapList lists the keys or values of a map in key order
+func builtinMapList(e *lenv, a *lval, function string) *lval {
+	if err := builtinMapArgs(a, function, 0); err != nil {
+		return err
+	}
+	if a.cellCount() != 1 {
+		return lvalErr("Function '%s' passed too many arguments: %s", function, a.lvalString())
+	}
+	if err := e.lenvState().lstateAlloc(len(a.cells[0].entries), 0); err != nil {
+		return err
+	}
+	x := lvalQexpr()
+	for _, entry := range a.cells[0].lvalMapEntries() {
+		if function == "keys" {
+			x = lvalAdd(x, entry.key)
+		} else {
+			x = lvalAdd(x, entry.val)
+		}
+	}
+	return x
+}
+
+func builtinContains(e *lenv, a *lval) *lval {
+	if err := builtinMapArgs(a, "contains?", 1); err != nil {
+		return err
+	}
+	if a.cellCount() != 2 {
+		return lvalErr("Function 'contains?' passed too many arguments: %s", a.lvalString())
+	}
+	return lvalBool(a.cells[0].lvalMapGet(a.cells[1]) != nil)
+}
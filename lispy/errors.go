@@ -0,0 +1,69 @@
+package lispy
+
+import "fmt"
+
+// ParseError describes input that does not match the Lispy grammar
+type ParseError struct {
+	File string
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (p *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: error: %s", p.File, p.Line, p.Col, p.Msg)
+}
+
+// ExitError is the cause of the error evaluation stops with when exit is
+// called, holding the status passed to it
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// EvalError is a Lispy error value handed back to Go
+type EvalError struct {
+	Msg string
+	// Where the error was raised. File is empty when that is not known.
+	File string
+	Line int
+	Col  int
+	// Calls the error propagated through, innermost first
+	Trace []string
+	// The value passed to throw, or the zero Value for other errors
+	Value Value
+
+	cause error
+}
+
+func (e *EvalError) Error() string {
+	if e.File == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Msg)
+}
+
+// Unwrap returns ErrCancelled for evaluation that was cancelled, the
+// matching error for evaluation that ran over its Limits, an *ExitError for
+// evaluation stopped by exit, and nil for errors raised by Lispy code
+func (e *EvalError) Unwrap() error {
+	return e.cause
+}
+
+// lvalError converts an lvalErrType into an *EvalError
+func lvalError(v *lval) *EvalError {
+	err := &EvalError{Msg: v.err, cause: v.cause}
+	if v.thrown != nil {
+		err.Value = Value{v.thrown}
+	}
+	if v.pos != nil {
+		err.File, err.Line, err.Col = v.pos.file, v.pos.line, v.pos.col
+	}
+	for _, frame := range v.trace {
+		err.Trace = append(err.Trace, frame.String())
+	}
+	return err
+}
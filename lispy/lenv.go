@@ -1,58 +1,61 @@
 package lispy
 
-import "github.com/sunzenshen/go-build-your-own-lisp/mpc"
-
 type lenv struct {
-	parser mpc.ParserPtr
-	par    *lenv
-	syms   map[string]*lval
+	par   *lenv
+	syms  map[string]*lval
+	state *lstate // Only set on the global environment
 }
 
 func lenvNew() *lenv {
 	e := new(lenv)
-	e.parser = nil
 	e.par = nil
 	e.syms = make(map[string]*lval)
 	return e
 }
 
-func (e *lenv) count() int {
-	return len(e.syms)
+// lenvState returns the state of the interpreter e belongs to
+func (e *lenv) lenvState() *lstate {
+	for e.state == nil && e.par != nil {
+		e = e.par
+	}
+	return e.state
 }
 
-func lenvCopy(e *lenv) *lenv {
-	n := new(lenv)
-	e.parser = nil
-	n.par = e.par
-	n.syms = make(map[string]*lval)
-	for k, v := range e.syms {
-		n.syms[k] = v
-	}
-	return n
+func (e *lenv) count() int {
+	return len(e.syms)
 }
 
 func (e *lenv) lenvGet(k *lval) *lval {
-	previous := e.syms[k.sym]
-	if previous != nil {
-		return lvalCopy(previous)
-	}
-
-	// If no symbol is found yet, check in the parent
-	if e.par != nil {
-		return e.par.lenvGet(k)
+	// Walk up the parents until the symbol is found
+	for ; e != nil; e = e.par {
+		previous := e.syms[k.sym]
+		if previous != nil {
+			// Values are shared, as nothing changes them once they are
+			// built. Errors are copied, as they collect a trace and
+			// position on the way out.
+			if previous.ltype == lvalErrType {
+				return lvalCopy(previous)
+			}
+			return previous
+		}
 	}
 	return lvalErr("Unbound Symbol: '%s'", k.sym)
 }
 
 func (e *lenv) lenvPut(k, v *lval) {
-	// If existing entry is found, overwrite it
-	previous := e.syms[k.sym]
-	if previous != nil {
-		e.syms[k.sym] = lvalCopy(v)
-	} else {
-		// If no existing entry is found, add new entry
-		e.syms[k.sym] = v
+	// Any existing entry is overwritten
+	e.syms[k.sym] = v
+}
+
+// lenvSet changes the value of the nearest existing binding of k
+func (e *lenv) lenvSet(k, v *lval) *lval {
+	for ; e != nil; e = e.par {
+		if e.syms[k.sym] != nil {
+			e.syms[k.sym] = v
+			return lvalSexpr()
+		}
 	}
+	return lvalErr("Unbound Symbol: '%s'", k.sym)
 }
 
 func (e *lenv) lenvDef(k *lval, v *lval) {
@@ -70,29 +73,70 @@ func (e *lenv) lenvAddBuiltin(name string, function lbuiltin) {
 	e.lenvPut(k, v)
 }
 
-func (e *lenv) lenvAddBuiltins() {
+func (e *lenv) lenvAddSpecial(name string, function lbuiltin) {
+	k := lvalSym(name)
+	v := lvalSpecial(function)
+	e.lenvPut(k, v)
+}
+
+// lenvAddBuiltins installs the builtin functions, leaving out those that
+// caps does not allow
+func (e *lenv) lenvAddBuiltins(caps Capabilities) {
 	// Define Functions
 	e.lenvAddBuiltin("def", builtinDef)
 	e.lenvAddBuiltin("=", builtinPut)
+	e.lenvAddBuiltin("set!", builtinSet)
 	e.lenvAddBuiltin("\\", builtinLambda)
+	// Macro Functions
+	e.lenvAddBuiltin("defmacro", builtinDefmacro)
+	e.lenvAddSpecial("quasiquote", builtinQuasiquote)
+	e.lenvAddSpecial("unquote", builtinUnquote)
+	e.lenvAddSpecial("unquote-splicing", builtinUnquoteSplicing)
 	// List Functions
 	e.lenvAddBuiltin("list", builtinList)
 	e.lenvAddBuiltin("head", builtinHead)
 	e.lenvAddBuiltin("tail", builtinTail)
 	e.lenvAddBuiltin("eval", builtinEval)
 	e.lenvAddBuiltin("join", builtinJoin)
+	// Map Functions
+	e.lenvAddBuiltin("hash-map", builtinHashMap)
+	e.lenvAddBuiltin("get", builtinGet)
+	e.lenvAddBuiltin("assoc", builtinAssoc)
+	e.lenvAddBuiltin("dissoc", builtinDissoc)
+	e.lenvAddBuiltin("keys", builtinKeys)
+	e.lenvAddBuiltin("values", builtinValues)
+	e.lenvAddBuiltin("contains?", builtinContains)
 	// Comparison Functions
 	e.lenvAddBuiltin("if", builtinIf)
+	e.lenvAddSpecial("case", builtinCase)
 	e.lenvAddBuiltin("==", builtinEqual)
 	e.lenvAddBuiltin("!=", builtinNotEqual)
 	e.lenvAddBuiltin(">", builtinGreaterThan)
 	e.lenvAddBuiltin("<", builtinLessThan)
 	e.lenvAddBuiltin(">=", builtinGreaterEqual)
 	e.lenvAddBuiltin("<=", builtinLessEqual)
+	// Logical Functions
+	e.lenvAddBuiltin("not", builtinNot)
+	e.lenvAddSpecial("and", builtinAnd)
+	e.lenvAddSpecial("or", builtinOr)
 	// String Functions
-	e.lenvAddBuiltin("load", builtinLoad)
 	e.lenvAddBuiltin("error", builtinError)
-	e.lenvAddBuiltin("print", builtinPrint)
+	if caps.Load {
+		e.lenvAddBuiltin("load", builtinLoad)
+	}
+	if caps.Print {
+		e.lenvAddBuiltin("print", builtinPrint)
+	}
+	if caps.Input {
+		e.lenvAddBuiltin("read-line", builtinReadLine)
+	}
+	// Error Functions
+	e.lenvAddBuiltin("try", builtinTry)
+	e.lenvAddBuiltin("throw", builtinThrow)
+	e.lenvAddBuiltin("error?", builtinIsError)
+	e.lenvAddBuiltin("error-message", builtinErrorMessage)
+	e.lenvAddBuiltin("error-value", builtinErrorValue)
+	e.lenvAddBuiltin("exit", builtinExit)
 	// Mathematical Functions
 	e.lenvAddBuiltin("+", builtinAdd)
 	e.lenvAddBuiltin("-", builtinSub)
@@ -100,4 +144,7 @@ func (e *lenv) lenvAddBuiltins() {
 	e.lenvAddBuiltin("/", builtinDiv)
 	e.lenvAddBuiltin("%", builtinMod)
 	e.lenvAddBuiltin("^", builtinPow)
+	e.lenvAddBuiltin("numerator", builtinNumerator)
+	e.lenvAddBuiltin("denominator", builtinDenominator)
+	e.lenvAddBuiltin("exact->inexact", builtinExactToInexact)
 }
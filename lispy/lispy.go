@@ -1,87 +1,110 @@
 package lispy
 
-import "fmt"
-import "github.com/sunzenshen/go-build-your-own-lisp/mpc"
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+)
 
-// Lispy is a collection of the Lispy parser definitions
+// Prelude is the source of the standard library, prelude.lspy
+//
+//go:embed prelude.lspy
+var Prelude string
+
+// Lispy holds the global environment of a Lispy interpreter
 type Lispy struct {
-	env           *lenv
-	numberParser  mpc.ParserPtr
-	symbolParser  mpc.ParserPtr
-	strParser     mpc.ParserPtr
-	commentParser mpc.ParserPtr
-	sexprParser   mpc.ParserPtr
-	qexprParser   mpc.ParserPtr
-	exprParser    mpc.ParserPtr
-	lispyParser   mpc.ParserPtr
-}
-
-// CleanLispy is used after parsers initiated by InitLispy are not longer to be used
+	env *lenv
+}
+
+// CleanLispy is used after an interpreter initiated by InitLispy is no longer to be used.
+// The reader is written in Go, so there is nothing left to release.
 func CleanLispy(l Lispy) {
-	mpc.MpcCleanup(
-		l.numberParser,
-		l.symbolParser,
-		l.strParser,
-		l.commentParser,
-		l.sexprParser,
-		l.qexprParser,
-		l.exprParser,
-		l.lispyParser)
-}
-
-// InitLispy returns the parsers for the Lispy language definition
+}
+
+// InitLispy returns an interpreter with the builtin functions loaded
 func InitLispy() Lispy {
-	number := mpc.MpcNew("number")
-	symbol := mpc.MpcNew("symbol")
-	str := mpc.MpcNew("string")
-	comment := mpc.MpcNew("comment")
-	sexpr := mpc.MpcNew("sexpr")
-	qexpr := mpc.MpcNew("qexpr")
-	expr := mpc.MpcNew("expr")
-	lispy := mpc.MpcNew("lispy")
-	language := "" +
-		"number  : /-?[0-9]+/                                                     ; " +
-		"symbol  : /[a-zA-Z0-9_+\\-*%^\\/\\\\=<>!&]+/                             ; " +
-		"string  : /\"(\\\\.|[^\"])*\"/                                           ; " +
-		"comment : /;[^\\r\\n]*/                                                  ; " +
-		"sexpr   : '(' <expr>* ')'                                                ; " +
-		"qexpr   : '{' <expr>* '}'                                                ; " +
-		"expr    : <number> | <symbol> | <string> | <comment> | <sexpr> | <qexpr> ; " +
-		"lispy   : /^/ <expr>* /$/                                                ; "
-	mpc.MpcaLang(language, number, symbol, str, comment, sexpr, qexpr, expr, lispy)
+	return InitLispyWithCapabilities(AllCapabilities)
+}
+
+// InitLispyWithCapabilities returns an interpreter with only the builtin
+// functions that caps allows loaded
+func InitLispyWithCapabilities(caps Capabilities) Lispy {
 	l := Lispy{}
-	l.numberParser = number
-	l.symbolParser = symbol
-	l.strParser = str
-	l.commentParser = comment
-	l.sexprParser = sexpr
-	l.qexprParser = qexpr
-	l.exprParser = expr
-	l.lispyParser = lispy
 	// Init environment
 	l.env = lenvNew()
-	l.env.lenvAddBuiltins()
-	l.env.parser = lispy // For loading files with builtin
+	l.env.state = lstateNew()
+	l.env.state.fsys = caps.FS
+	l.env.lenvAddBuiltins(caps)
 	return l
 }
 
+// SetStdout sets where print and the results of ReadEvalPrint are written.
+// It is os.Stdout unless set otherwise.
+func (l *Lispy) SetStdout(w io.Writer) {
+	l.env.state.stdout = w
+}
+
+// SetStderr sets where errors and other diagnostics are written. It is
+// os.Stderr unless set otherwise.
+func (l *Lispy) SetStderr(w io.Writer) {
+	l.env.state.stderr = w
+}
+
+// SetStdin sets where read-line reads from. It is os.Stdin unless set
+// otherwise.
+func (l *Lispy) SetStdin(r io.Reader) {
+	l.env.state.stdin = bufio.NewReader(r)
+}
+
+// SetLimits sets the resources each evaluation started from Go may use.
+// Evaluation that runs over them fails with an *EvalError wrapping ErrSteps,
+// ErrDepth, ErrCells, ErrStringBytes or ErrNumberBits.
+func (l *Lispy) SetLimits(limits Limits) {
+	l.env.state.limits = limits
+}
+
 // PrintAst prints the AST of a Lispy expression.
 func (l *Lispy) PrintAst(input string) {
-	mpc.PrintAst(input, l.lispyParser)
+	if err := printAst(l.env.state.stdout, "<stdin>", input); err != nil {
+		fmt.Fprintln(l.env.state.stderr, err)
+	}
 }
 
 // Read takes a string and parses it into an lval
 func (l *Lispy) Read(input string, printErrors bool) *lval {
-	r, err := mpc.ParseString(input, l.lispyParser)
+	x, err := lvalReadString("<stdin>", input)
 	if err != nil {
 		if printErrors {
-			mpc.PrintError(&r)
+			fmt.Fprintln(l.env.state.stderr, err)
+		}
+		p := err.(*ParseError)
+		return lvalErr("Failed to parse input: '%s'", input).lvalAt(&lpos{p.File, p.Line, p.Col})
+	}
+	return x
+}
+
+// Incomplete reports whether input ends inside a string or with brackets or
+// braces left open, so that more lines are needed to finish reading it
+func Incomplete(input string) bool {
+	x := newLexer("<stdin>", input)
+	depth := 0
+	for {
+		t, err := x.next()
+		if err != nil {
+			return err.(*ParseError).Msg == "unterminated string"
+		}
+		switch t.kind {
+		case tokOpen:
+			depth++
+		case tokClose:
+			depth--
+		case tokEOF:
+			return depth > 0
 		}
-		mpc.DeleteError(&r)
-		return lvalErr("Failed to parse input: '%s'", input)
 	}
-	defer mpc.DeleteAstPtr(&r)
-	return lvalRead(mpc.GetOutput(&r))
 }
 
 // Eval translates an lval into the final result of the represented instructions
@@ -91,25 +114,181 @@ func (v *lval) Eval(e *lenv) *lval {
 
 // ReadEval takes a string, tries to interpret it in Lispy
 func (l *Lispy) ReadEval(input string, printErrors bool) *lval {
+	defer l.env.state.lstateBegin()()
 	return l.Read(input, printErrors).Eval(l.env)
 }
 
+// Eval reads and evaluates src as ReadEval does, without printing anything.
+// Input that cannot be parsed gives a *ParseError, and evaluation that ends
+// in a Lispy error gives an *EvalError.
+func (l *Lispy) Eval(ctx context.Context, src string) (Value, error) {
+	defer l.withContext(ctx)()
+	defer l.env.state.lstateBegin()()
+	x, err := lvalReadString("<eval>", src)
+	if err != nil {
+		return Value{}, err
+	}
+	v := x.lvalEval(l.env)
+	if v.ltype == lvalErrType {
+		return Value{}, lvalError(v)
+	}
+	return Value{v}, nil
+}
+
+// LoadFile evaluates each expression of a source file in turn, stopping at
+// the first error. Nothing is printed. The file is read from the FS of the
+// interpreter's Capabilities when one was given.
+func (l *Lispy) LoadFile(path string) error {
+	contents, err := l.env.state.lstateReadFile(path)
+	if err != nil {
+		return err
+	}
+	return l.LoadSource(path, string(contents))
+}
+
+// LoadFileContext is LoadFile with evaluation stopped once ctx is cancelled,
+// which fails with an *EvalError wrapping ErrCancelled
+func (l *Lispy) LoadFileContext(ctx context.Context, path string) error {
+	defer l.withContext(ctx)()
+	return l.LoadFile(path)
+}
+
+// LoadPrelude loads the standard library built into the interpreter
+func (l *Lispy) LoadPrelude() error {
+	return l.LoadSource("prelude.lspy", Prelude)
+}
+
+// LoadSource evaluates each expression of src as LoadFile does, reporting
+// positions in the file named name
+func (l *Lispy) LoadSource(name, src string) error {
+	expr, err := lvalReadString(name, src)
+	if err != nil {
+		return err
+	}
+	defer l.env.state.lstateBegin()()
+	if x := lvalEvalAll(l.env, expr); x.ltype == lvalErrType {
+		return lvalError(x)
+	}
+	return nil
+}
+
+// withContext makes ctx the context of evaluation, until the function it
+// returns is called. Evaluation in a context that is already cancelled
+// stops at its first step.
+func (l *Lispy) withContext(ctx context.Context) func() {
+	st := l.env.state
+	prev, cancelled := st.ctx, st.cancelled
+	st.ctx, st.cancelled = ctx, ctx.Err() != nil
+	return func() {
+		st.ctx, st.cancelled = prev, cancelled
+	}
+}
+
 // ReadEvalPrint takes a string, tries to interpret it in Lispy, and prints the result
-func (l *Lispy) ReadEvalPrint(input string) {
-	l.ReadEval(input, true).lvalPrintLn()
+func (l *Lispy) ReadEvalPrint(input string) error {
+	return l.ReadEvalPrintContext(context.Background(), input)
+}
+
+// ReadEvalPrintContext is ReadEvalPrint with evaluation stopped once ctx is
+// cancelled. A failed evaluation is returned as an *EvalError after it is
+// printed, except one stopped by exit, which is returned without printing.
+func (l *Lispy) ReadEvalPrintContext(ctx context.Context, input string) error {
+	defer l.withContext(ctx)()
+	x := l.ReadEval(input, true)
+	if x.ltype != lvalErrType {
+		x.lvalPrintLn(l.env.state.stdout)
+		return nil
+	}
+	if _, ok := x.cause.(*ExitError); !ok {
+		x.lvalPrintLn(l.env.state.stderr)
+	}
+	return lvalError(x)
 }
 
 // LoadFiles loads a list of files into the Lispy environment
 func (l *Lispy) LoadFiles(files []string) {
 	for _, file := range files {
-		fmt.Println("Loading: ", file)
+		fmt.Fprintln(l.env.state.stdout, "Loading: ", file)
 		// Argument list with a single argument, the file name
 		args := lvalAdd(lvalSexpr(), lvalStr(file))
 		// Pass into builtin load to get the result
+		end := l.env.state.lstateBegin()
 		x := builtinLoad(l.env, args)
+		end()
 		// If the result is an error, print it
 		if x.ltype == lvalErrType {
-			x.lvalPrintLn()
+			x.lvalPrintLn(l.env.state.stderr)
+		}
+	}
+}
+
+// Env is an environment of Lispy symbols, through which a host program can
+// look up, define and call Lispy values
+type Env struct {
+	env *lenv
+}
+
+// Env returns the global environment of the interpreter
+func (l *Lispy) Env() *Env {
+	return &Env{l.env}
+}
+
+// Get returns the value bound to name
+func (e *Env) Get(name string) (Value, bool) {
+	x := e.env.lenvGet(lvalSym(name))
+	if x.ltype == lvalErrType {
+		return Value{}, false
+	}
+	return Value{x}, true
+}
+
+// Def binds name to a copy of v in the global environment, as def does
+func (e *Env) Def(name string, v Value) {
+	e.env.lenvDef(lvalSym(name), lvalCopy(v.lval()))
+}
+
+// Names lists the symbols bound in e and its parents, in sorted order
+func (e *Env) Names() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for env := e.env; env != nil; env = env.par {
+		for name := range env.syms {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
 		}
 	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval evaluates v in e. Failures are returned as a Value of ErrorKind.
+func (e *Env) Eval(v Value) Value {
+	defer e.env.lenvState().lstateBegin()()
+	return Value{lvalCopy(v.lval()).lvalEval(e.env)}
+}
+
+// EvalString reads src and evaluates it in e, as ReadEval does
+func (e *Env) EvalString(src string) Value {
+	l := Lispy{e.env}
+	return Value{l.ReadEval(src, false)}
+}
+
+// Call applies the function fn to args, which are passed in as they are
+// without being evaluated
+func (e *Env) Call(fn Value, args ...Value) Value {
+	defer e.env.lenvState().lstateBegin()()
+	f := lvalCopy(fn.lval())
+	if f.ltype != lvalFunType {
+		return Value{lvalErr("Cannot call non-function. Got type %s instead", f.ltypeName())}
+	}
+	x := lvalCall(e.env, f, lvalValues(lvalSexpr(), args))
+	if f.macro {
+		x = lvalExpand(e.env, x)
+	}
+	if x.ltype == lvalThunkType {
+		x = x.body.lvalEval(x.env)
+	}
+	return Value{x}
 }
@@ -1,10 +1,26 @@
 package lispy
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
 
 // Truth values for evaluated output
-const truth = "1"
-const falsity = "0"
+const truth = "#t"
+const falsity = "#f"
 
 func TestLvalRead(t *testing.T) {
 	l := InitLispy()
@@ -29,6 +45,86 @@ func TestLvalRead(t *testing.T) {
 	}
 }
 
+func TestReaderTokens(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input, want string
+	}{
+		{"", "()"},
+		{"  \t\n ", "()"},
+		{"-5", "(-5)"},
+		{"- 5", "(- 5)"},
+		{"-5abc", "(-5 abc)"},
+		{"abc-5", "(abc-5)"},
+		{"1-2", "(1 -2)"},
+		{"(+ 1 2)(- 3 4)", "((+ 1 2) (- 3 4))"},
+		{"{a {b ; comment\n c}}", "({a {b c}})"},
+		{"\"a\\tb\"", "(\"a\\tb\")"},
+		{"\"multi\nline\"", "(\"multi\\nline\")"},
+		{"\"unknown \\q escape\"", "(\"unknown \\\\q escape\")"},
+		{"99999999999999999999", "(99999999999999999999)"},
+		{"-99999999999999999999", "(-99999999999999999999)"},
+		{"#!/usr/bin/env lispy\n(+ 1 2)", "((+ 1 2))"},
+		{"#!/usr/bin/env lispy", "()"},
+	}
+
+	for _, c := range cases {
+		got := l.Read(c.input, false).lvalString()
+		if got != c.want {
+			t.Errorf("Read input: \"%s\" returned %s, actually expected %s", c.input, got, c.want)
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"(+ 1 2", "<stdin>:1:7: error: expected ')' to close '(' at 1:1"},
+		{"{1 2)", "<stdin>:1:5: error: expected '}' to close '{' at 1:1, got ')'"},
+		{"1 2)", "<stdin>:1:4: error: unexpected ')'"},
+		{"\"open", "<stdin>:1:1: error: unterminated string"},
+		{"(a\n  .)", "<stdin>:2:3: error: unexpected character '.'"},
+		{"1\n#!/usr/bin/env lispy", "<stdin>:2:1: error: unexpected character '#'"},
+	}
+
+	for _, c := range cases {
+		_, err := lvalReadString("<stdin>", c.input)
+		if err == nil {
+			t.Errorf("lvalReadString input: \"%s\" returned no error, actually expected \"%s\"", c.input, c.want)
+		} else if err.Error() != c.want {
+			t.Errorf("lvalReadString input: \"%s\" returned \"%s\", actually expected \"%s\"", c.input, err, c.want)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	cases := []struct {
+		input string
+		want  bool
+	}{
+		{"+ 1 2", false},
+		{"(+ 1 2", true},
+		{"(fun {f x} {\n  + x 1", true},
+		{"(fun {f x} {\n  + x 1\n})", false},
+		{"#{\"a\" 1", true},
+		{"print \"line one\nline two", true},
+		{"print \"a \\\" b\"", false},
+		{"print \"(\"", false},
+		{"(+ 1 ; comment )", true},
+		{"1 2)", false},
+		{"(a .", false},
+	}
+
+	for _, c := range cases {
+		if got := Incomplete(c.input); got != c.want {
+			t.Errorf("Incomplete input: \"%s\" returned: %t, actually expected: %t", c.input, got, c.want)
+		}
+	}
+}
+
 func TestValidIntegerMath(t *testing.T) {
 	l := InitLispy()
 	defer CleanLispy(l)
@@ -48,7 +144,6 @@ func TestValidIntegerMath(t *testing.T) {
 		{"/ 9 3", 3},
 		{"/ -9 3", -3},
 		{"/ -9 -3", 3},
-		{"/ 7 3", 2},
 		{"+ 5 6", 11},
 		{"- (* 10 10) (+ 1 1 1)", 97},
 		{"+ 1 (* 7 5) 3", 39},
@@ -66,6 +161,146 @@ func TestValidIntegerMath(t *testing.T) {
 	}
 }
 
+func TestFloatMath(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"3.14", "3.14"},
+		{"-0.5", "-0.5"},
+		{"1e-9", "1e-09"},
+		{"2.5E3", "2500.0"},
+		{"1e", "Error: S-expression does not start with symbol! got: Number"},
+		{"+ 1.5 1.5", "3.0"},
+		{"+ 1 0.5", "1.5"},
+		{"* 2 0.25", "0.5"},
+		{"- 1.5", "-1.5"},
+		{"- 10 0.5 0.5", "9.0"},
+		{"/ 7 2.0", "3.5"},
+		{"/ 1.0 0", "Error: Division By Zero!"},
+		{"% 7.5 2", "1.5"},
+		{"^ 2 0.5", "1.4142135623730951"},
+		{"^ 2 -1", "1/2"},
+		{"^ 2 10", "1024"},
+		{"> 1.5 1", truth},
+		{"<= 2 1.5", falsity},
+		{"< -0.1 0", truth},
+		{"== 1 1.0", truth},
+		{"== 0.1 0.2", falsity},
+		{"== {1.5 2} {1.5 2.0}", truth},
+		{"+ 1.0 \"x\"", "Error: Cannot operate on non-number: String"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestBigIntegerMath(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"* 9223372036854775807 2", "18446744073709551614"},
+		{"+ 9223372036854775807 1", "9223372036854775808"},
+		{"- -9223372036854775808 1", "-9223372036854775809"},
+		{"- -9223372036854775808", "9223372036854775808"},
+		{"/ -9223372036854775808 -1", "9223372036854775808"},
+		{"% -9223372036854775808 -1", "0"},
+		{"^ 2 100", "1267650600228229401496703205376"},
+		{"^ 2 62", "4611686018427387904"},
+		{"^ -3 3", "-27"},
+		{"^ 2 (^ 2 40)", "Error: Power too large: result would have over 1073741824 bits"},
+		{"^ 1/2 (^ 2 40)", "Error: Power too large: result would have over 1073741824 bits"},
+		{"^ -1 (^ 2 40)", "1"},
+		{"- (* 9223372036854775807 2) 9223372036854775807", "9223372036854775807"},
+		{"/ 100000000000000000000 10", "10000000000000000000"},
+		{"/ 100000000000000000000 100", "1000000000000000000"},
+		{"% 100000000000000000007 10", "7"},
+		{"/ 100000000000000000000 0", "Error: Division By Zero!"},
+		{"+ 100000000000000000000 0.5", "1e+20"},
+		{"> 100000000000000000000 9223372036854775807", truth},
+		{"< -100000000000000000000 1", truth},
+		{"== 100000000000000000000 (* 10000000000 10000000000)", truth},
+		{"== (- (+ 9223372036854775807 1) 1) 9223372036854775807", truth},
+		{"== 100000000000000000000 100000000000000000001", falsity},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+	// Results that fit an int64 again are demoted back to small integers
+	got := l.ReadEval("- (+ 9223372036854775807 1) 1", false)
+	if got.bnum != nil || got.num != 9223372036854775807 {
+		t.Errorf("ReadEval returned %s with big %v, actually expected a small integer", got.lvalString(), got.bnum)
+	}
+}
+
+func TestRationalMath(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"1/3", "1/3"},
+		{"-2/4", "-1/2"},
+		{"4/2", "2"},
+		{"1/0", "Error: Invalid Number: 1/0"},
+		{"/ 7 3", "7/3"},
+		{"/ 6 3", "2"},
+		{"/ -1 3", "-1/3"},
+		{"/ 1 100000000000000000000", "1/100000000000000000000"},
+		{"+ 1/3 1/6", "1/2"},
+		{"+ 1/3 2/3", "1"},
+		{"- 1/2", "-1/2"},
+		{"* 2/3 3", "2"},
+		{"/ 1/2 1/4", "2"},
+		{"/ 1/2 0", "Error: Division By Zero!"},
+		{"% 7/2 1", "1/2"},
+		{"% -7/2 1", "-1/2"},
+		{"^ 2/3 2", "4/9"},
+		{"^ 2/3 -2", "9/4"},
+		{"^ 0 -1", "Error: Division By Zero!"},
+		{"^ 4 1/2", "2.0"},
+		{"+ 1/2 0.25", "0.75"},
+		{"< 1/3 0.34", truth},
+		{"> 1/3 1/4", truth},
+		{"== 1/2 0.5", truth},
+		{"== 2/4 1/2", truth},
+		{"== 1/3 1", falsity},
+		{"numerator 6/4", "3"},
+		{"denominator 6/4", "2"},
+		{"numerator 5", "5"},
+		{"denominator -5", "1"},
+		{"denominator 0.5", "Error: denominator passed non-exact number: Float"},
+		{"exact->inexact 1/4", "0.25"},
+		{"exact->inexact 3", "3.0"},
+		{"exact->inexact 0.5", "0.5"},
+		{"* 100 (/ 1 3) 3", "100"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
 func TestListFunctions(t *testing.T) {
 	l := InitLispy()
 	defer CleanLispy(l)
@@ -153,6 +388,63 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input, want string
+	}{
+		{"hello", "<stdin>:1:1"},
+		{"+ 1 hello", "<stdin>:1:5"},
+		{"(+ 1\n  (head {}))", "<stdin>:2:3"},
+		{"\n\n  (1 2 3)", "<stdin>:3:3"},
+		{"+ 1 (2 .)", "<stdin>:1:8"},
+		{"def {f} (\\ {x} {+ x {}})", ""},
+		{"f 1", "<stdin>:1:16"},
+		{"eval {\n  + 1 {}}", "<stdin>:1:6"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if c.want == "" {
+			continue
+		}
+		if got.ltype != lvalErrType {
+			t.Errorf("ReadEval input: \"%s\" returned ltype %s, actually expected lvalErrType", c.input, got.ltypeName())
+		} else if got.pos == nil {
+			t.Errorf("ReadEval input: \"%s\" returned error without position, actually expected %s", c.input, c.want)
+		} else if got.pos.String() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned position %s, actually expected %s", c.input, got.pos, c.want)
+		}
+	}
+}
+
+func TestErrorTraces(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("def {inner} (\\ {x} {head x})", false)
+	l.ReadEval("def {outer} (\\ {y} {+ 1 (inner y)})", false)
+
+	got := l.ReadEval("outer {}", false)
+	want := []string{
+		"at head (<stdin>:1:20)",
+		"at inner (<stdin>:1:25)",
+		"at outer (<stdin>:1:1)",
+	}
+	if got.ltype != lvalErrType {
+		t.Fatalf("ReadEval returned ltype %s, actually expected lvalErrType", got.ltypeName())
+	}
+	if len(got.trace) != len(want) {
+		t.Fatalf("ReadEval returned trace %v, actually expected %v", got.trace, want)
+	}
+	for i, frame := range got.trace {
+		if frame.String() != want[i] {
+			t.Errorf("Trace frame %d is \"%s\", actually expected \"%s\"", i, frame, want[i])
+		}
+	}
+}
+
 func TestFunctionDefinitions(t *testing.T) {
 	l := InitLispy()
 	defer CleanLispy(l)
@@ -236,8 +528,9 @@ func TestConditionals(t *testing.T) {
 		{"if (== x y) {+ x y} {- x y}", "-100"},
 		// Standard Library
 		{"== nil {}", truth},
-		{"== true 1", truth},
-		{"== false 0", truth},
+		{"== true #t", truth},
+		{"== false #f", truth},
+		{"== true 1", falsity},
 		{"!= true false", truth},
 	}
 
@@ -249,6 +542,178 @@ func TestConditionals(t *testing.T) {
 	}
 }
 
+func TestBooleans(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"#t", truth},
+		{"{#t #f}", "{#t #f}"},
+		{"== #t #t", truth},
+		{"== #t #f", falsity},
+		{"!= #f 0", truth},
+		{"< 1 2", truth},
+		// Truthiness of conditions
+		{"if #f {1} {2}", "2"},
+		{"if 0 {1} {2}", "2"},
+		{"if 0.0 {1} {2}", "2"},
+		{"if {} {1} {2}", "2"},
+		{"if 5 {1} {2}", "1"},
+		{"if \"\" {1} {2}", "1"},
+		{"if {0} {1} {2}", "1"},
+		{"not 5", falsity},
+		{"not 0", truth},
+		{"not nil", truth},
+		// Logical operators return the deciding value
+		{"or 1 1", "1"},
+		{"or #f 2", "2"},
+		{"and 1 2 3", "3"},
+		{"and 1 0 3", "0"},
+		// Short-circuiting
+		{"or #t (error \"unreachable\")", truth},
+		{"and #f (error \"unreachable\")", falsity},
+		{"and #t (error \"reached\")", "Error: reached"},
+		{"or #f undefined-symbol", "Error: Unbound Symbol: 'undefined-symbol'"},
+		{"def {either} or", "()"},
+		{"either #f #t", truth},
+		{"filter (\\ {x} {or (> x 10) (< x 0)}) {5 -2 11 7}", "{-2 11}"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"#{}", "#{}"},
+		{"#{\"b\" 2 \"a\" 1}", "#{\"a\" 1 \"b\" 2}"},
+		{"#{\"k\" (+ 1 2)}", "#{\"k\" 3}"},
+		{"{#{a (+ 1 2)}}", "{#{a (+ 1 2)}}"},
+		// Keys and values of map literals are evaluated, as those of hash-map are
+		{"def {a} \"key\"", "()"},
+		{"#{a (+ 1 2)}", "#{\"key\" 3}"},
+		{"dissoc #{a 1} a", "#{}"},
+		{"#{b 1}", "Error: Unbound Symbol: 'b'"},
+		{"#{+ 1}", "Error: Map keys cannot be of type Function"},
+		// Entries are evaluated in the order they are written, and the last
+		// of those that share a key wins
+		{"def {log} {}", "()"},
+		{"#{\"b\" (set! {log} (join log {1})) \"a\" (set! {log} (join log {2}))}", "#{\"a\" () \"b\" ()}"},
+		{"log", "{1 2}"},
+		{"def {k1 k2} \"k\" \"k\"", "()"},
+		{"#{k2 1 k1 2}", "#{\"k\" 2}"},
+		{"def {n} 0", "()"},
+		{"#{(set! {n} (+ n 1)) 1 (set! {n} (+ n 1)) 2}", "#{() 2}"},
+		{"n", "2"},
+		{"{#{x 1 x 2}}", "{#{x 1 x 2}}"},
+		{"get `#{\"a\" 1} \"a\"", "Error: Function 'get' passed a map literal that has not been evaluated: #{\"a\" 1}"},
+		{"hash-map \"x\" (+ 1 2) 1 {1 2}", "#{1 {1 2} \"x\" 3}"},
+		{"hash-map 1", "Error: Function 'hash-map' needs an even number of arguments, got 1"},
+		{"hash-map + 1", "Error: Map keys cannot be of type Function"},
+		{"def {m} #{\"one\" 1 \"two\" 2}", "()"},
+		{"get m \"one\"", "1"},
+		{"get m \"three\"", "Error: Key not found in map: \"three\""},
+		{"get m \"three\" 3", "3"},
+		{"get {} 1", "Error: Function 'get' passed incorrect type: Q-Expression"},
+		{"assoc m \"three\" 3", "#{\"one\" 1 \"three\" 3 \"two\" 2}"},
+		{"assoc m \"one\" 100", "#{\"one\" 100 \"two\" 2}"},
+		{"assoc m \"three\"", "Error: Function 'assoc' passed too few arguments: (#{\"one\" 1 \"two\" 2} \"three\")"},
+		{"assoc m 3 3 4", "Error: Function 'assoc' needs a value for each key: (#{\"one\" 1 \"two\" 2} 3 3 4)"},
+		{"dissoc m \"one\" \"missing\"", "#{\"two\" 2}"},
+		{"m", "#{\"one\" 1 \"two\" 2}"}, // Check for accidental modification
+		{"keys m", "{\"one\" \"two\"}"},
+		{"values m", "{1 2}"},
+		{"contains? m \"two\"", truth},
+		{"contains? m 2", falsity},
+		// Numeric keys that are equal share an entry
+		{"get (hash-map 1 \"int\") 1.0", "\"int\""},
+		{"get (hash-map 1/2 \"half\") 0.5", "\"half\""},
+		// Floats are keyed on their exact value, and NaN on itself
+		{"get (hash-map 0.1 \"float\") (/ 1 10) \"missing\"", "\"missing\""},
+		{"get (hash-map (- (^ 10.0 400) (^ 10.0 400)) \"nan\") (* 0.0 (^ 10.0 400))", "\"nan\""},
+		{"get (hash-map {1 2} \"list\") {1 2}", "\"list\""},
+		{"get (hash-map {1 {2}} \"nested\") {1.0 {2.0}} \"missing\"", "\"nested\""},
+		{"get (hash-map (hash-map \"k\" 1) \"map\") (hash-map \"k\" 1.0) \"missing\"", "\"map\""},
+		{"get (hash-map {1} \"list\") {\"1\"} \"missing\"", "\"missing\""},
+		{"== #{\"a\" 1 \"b\" 2} #{\"b\" 2 \"a\" 1}", truth},
+		{"== #{\"a\" 1} #{\"a\" 2}", falsity},
+		{"== #{\"a\" 1} #{\"b\" 1}", falsity},
+		{"head {#{a 1} 2}", "{#{a 1}}"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+
+	if _, err := lvalReadString("<stdin>", "#{a}"); err == nil {
+		t.Errorf("lvalReadString input: \"#{a}\" returned no error for an odd map literal")
+	}
+}
+
+func TestMacros(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		// Reader syntax
+		{"{`a ,b ,@c}", "{(quasiquote a) (unquote b) (unquote-splicing c)}"},
+		{"{` ; comment\n a}", "{(quasiquote a)}"},
+		// Quasiquote
+		{"def {x} 5", "()"},
+		{"`x", "x"},
+		{"`(+ 1 ,x)", "(+ 1 5)"},
+		{"`{1 ,(+ 1 1) ,@{3 4} 5}", "{1 2 3 4 5}"},
+		{"`{a {b ,x}}", "{a {b 5}}"},
+		{"`{,@{}}", "{}"},
+		{"`{,@x}", "Error: unquote-splicing passed non-list: Number"},
+		{"`{,y}", "Error: Unbound Symbol: 'y'"},
+		{",x", "Error: unquote used outside of quasiquote"},
+		// Macros
+		{"defmacro {unless c a b} {`(if ,c ,b ,a)}", "()"},
+		{"unless", "(macro {c a b} {(quasiquote (if (unquote c) (unquote b) (unquote a)))})"},
+		{"unless (== x 5) {\"no\"} {\"yes\"}", "\"yes\""},
+		{"unless (== x 6) {\"no\"} {\"yes\"}", "\"no\""},
+		{"defmacro {ignore code} {{nil}}", "()"},
+		{"ignore (error \"never evaluated\")", "{}"},
+		{"defmacro {quote-args & xs} {`{{,@xs}}}", "()"},
+		{"quote-args (+ 1 2) y", "{(+ 1 2) y}"},
+		{"defmacro {when c & body} {`(if ,c {do ,@body} {nil})}", "()"},
+		{"when (> x 1) (= {y} (* x 2)) (+ y 1)", "11"},
+		{"when (> x 10) (error \"never evaluated\")", "{}"},
+		{"defmacro {swap-args f a b} {`(,f ,b ,a)}", "()"},
+		{"swap-args - 1 10", "9"},
+		{"defmacro {bad 1} {}", "Error: Cannot define non-symbol. Got type Number instead"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
 func TestRecursiveFunctions(t *testing.T) {
 	l := InitLispy()
 	defer CleanLispy(l)
@@ -528,3 +993,837 @@ func TestPowerOperator(t *testing.T) {
 		}
 	}
 }
+
+func TestTailCalls(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+
+	// Deep recursion would overflow this stack unless tail calls run in a loop
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"fun {count-down n} {if (== n 0) {\"done\"} {count-down (- n 1)}}", "()"},
+		{"count-down 5000", "\"done\""},
+		{"fun {count-select n} {select {(== n 0) \"done\"} {otherwise (count-select (- n 1))}}", "()"},
+		{"count-select 5000", "\"done\""},
+		{"fun {count-case n} {case n {0 \"done\"} {n (count-case (- n 1))}}", "()"},
+		{"count-case 5000", "\"done\""},
+		{"fun {count-do n} {do (= {m} (- n 1)) (if (< m 0) {\"done\"} {count-do m})}", "()"},
+		{"count-do 500", "\"done\""},
+		{"fun {count-eval n} {eval {if (== n 0) {\"done\"} {count-eval (- n 1)}}}", "()"},
+		{"count-eval 5000", "\"done\""},
+		// Walking a list of a few hundred thousand items
+		{"def {xs} {" + strings.Repeat("1 ", 199999) + "2}", "()"},
+		{"len xs", "200000"},
+		{"nth 199999 xs", "2"},
+		{"len (drop 199990 xs)", "10"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	env := l.Env()
+
+	if got := env.EvalString("+ 1 2"); got.Kind() != NumberKind {
+		t.Errorf("EvalString returned kind %s, actually expected %s", got.Kind(), NumberKind)
+	} else if n, ok := got.Int(); !ok || n != 3 {
+		t.Errorf("Int returned %d, %v, actually expected 3, true", n, ok)
+	}
+	if n, ok := env.EvalString("^ 2 100").Int(); ok {
+		t.Errorf("Int of a big integer returned %d, actually expected no value", n)
+	}
+	if b, ok := env.EvalString("^ 2 100").BigInt(); !ok || b.String() != "1267650600228229401496703205376" {
+		t.Errorf("BigInt returned %v, %v, actually expected 2^100", b, ok)
+	}
+	if r, ok := env.EvalString("/ 1 3").Rat(); !ok || r.RatString() != "1/3" {
+		t.Errorf("Rat returned %v, %v, actually expected 1/3", r, ok)
+	}
+	if f, ok := env.EvalString("/ 1 4").Float(); !ok || f != 0.25 {
+		t.Errorf("Float returned %v, %v, actually expected 0.25", f, ok)
+	}
+	if b, ok := env.EvalString("> 2 1").Bool(); !ok || !b {
+		t.Errorf("Bool returned %v, %v, actually expected true, true", b, ok)
+	}
+	if s, ok := env.EvalString("\"a\\nb\"").Text(); !ok || s != "a\nb" {
+		t.Errorf("Text returned %q, %v, actually expected \"a\\nb\", true", s, ok)
+	}
+	if s, ok := env.EvalString("head {x}").List(); !ok || len(s) != 1 {
+		t.Errorf("List returned %v, %v, actually expected one element", s, ok)
+	} else if name, ok := s[0].Symbol(); !ok || name != "x" {
+		t.Errorf("Symbol returned %q, %v, actually expected \"x\", true", name, ok)
+	}
+	if entries, ok := env.EvalString("#{\"b\" 2 \"a\" 1}").Entries(); !ok || len(entries) != 2 {
+		t.Errorf("Entries returned %v, %v, actually expected two entries", entries, ok)
+	} else if entries[0].Key.String() != "\"a\"" || entries[0].Value.String() != "1" {
+		t.Errorf("Entries returned first entry %v, actually expected \"a\" 1", entries[0])
+	}
+	if err := env.EvalString("error \"boom\"").Err(); err == nil || err.Error() != "<stdin>:1:1: boom" {
+		t.Errorf("Err returned %v, actually expected boom", err)
+	}
+	if err := env.EvalString("1").Err(); err != nil {
+		t.Errorf("Err returned %v, actually expected nil", err)
+	}
+	if _, ok := NewString("1").Int(); ok {
+		t.Errorf("Int of a string returned a value, actually expected none")
+	}
+	if got := (Value{}).String(); got != "()" {
+		t.Errorf("Zero Value printed as %s, actually expected ()", got)
+	}
+}
+
+func TestValueConstructors(t *testing.T) {
+	m, err := NewMap(Entry{NewSymbol("k"), NewFloat(1.5)})
+	if err != nil {
+		t.Fatalf("NewMap returned %v", err)
+	}
+	if _, err := NewMap(Entry{NewError("x"), NewInt(1)}); err == nil {
+		t.Errorf("NewMap with an error key returned no error")
+	}
+	cases := []struct {
+		value Value
+		want  string
+	}{
+		{NewInt(-7), "-7"},
+		{NewBigInt(new(big.Int).Lsh(big.NewInt(1), 70)), "1180591620717411303424"},
+		{NewRat(big.NewRat(6, 4)), "3/2"},
+		{NewRat(big.NewRat(4, 2)), "2"},
+		{NewFloat(2), "2.0"},
+		{NewBool(false), "#f"},
+		{NewString("say \"hi\""), "\"say \\\"hi\\\"\""},
+		{NewSymbol("foo"), "foo"},
+		{NewError("bad"), "Error: bad"},
+		{NewList(NewInt(1), NewList(NewString("a"))), "{1 {\"a\"}}"},
+		{NewSExpr(NewSymbol("+"), NewInt(1)), "(+ 1)"},
+		{m, "#{k 1.5}"},
+	}
+
+	for _, c := range cases {
+		if got := c.value.String(); got != c.want {
+			t.Errorf("Value printed as \"%s\", actually expected: \"%s\"", got, c.want)
+		}
+	}
+	if !NewInt(2).Equal(NewFloat(2)) || NewInt(2).Equal(NewString("2")) {
+		t.Errorf("Equal did not compare values as == does")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	env := l.Env()
+
+	list := NewList(NewInt(1), NewInt(2), NewInt(3))
+	env.Def("xs", list)
+	if got := env.EvalString("eval (join {+} xs)").String(); got != "6" {
+		t.Errorf("EvalString returned %s, actually expected 6", got)
+	}
+	// Evaluation does not change values held by the host
+	env.EvalString("def {ys} (tail xs)")
+	if got := list.String(); got != "{1 2 3}" {
+		t.Errorf("Defined value changed to %s, actually expected {1 2 3}", got)
+	}
+	if got, ok := env.Get("ys"); !ok || got.String() != "{2 3}" {
+		t.Errorf("Get returned %s, %v, actually expected {2 3}, true", got, ok)
+	}
+	if _, ok := env.Get("zs"); ok {
+		t.Errorf("Get of an unbound symbol returned a value")
+	}
+
+	// Arguments to Call are not evaluated
+	env.EvalString("def {pair} (\\ {a b} {list a b})")
+	pair, _ := env.Get("pair")
+	if got := env.Call(pair, NewSymbol("x"), NewSExpr()).String(); got != "{x ()}" {
+		t.Errorf("Call returned %s, actually expected {x ()}", got)
+	}
+	plus, _ := env.Get("+")
+	if got := env.Call(plus, NewInt(1), NewFloat(0.5)).String(); got != "1.5" {
+		t.Errorf("Call returned %s, actually expected 1.5", got)
+	}
+	if got := env.Call(pair, NewInt(1)).Kind(); got != FunctionKind {
+		t.Errorf("Partial Call returned %s, actually expected %s", got, FunctionKind)
+	}
+	if got := env.Call(NewInt(1)).String(); got != "Error: Cannot call non-function. Got type Number instead" {
+		t.Errorf("Call of a number returned %s", got)
+	}
+	if got := env.Eval(NewSExpr(NewSymbol("*"), NewInt(6), NewInt(7))).String(); got != "42" {
+		t.Errorf("Eval returned %s, actually expected 42", got)
+	}
+
+	names := env.Names()
+	found := 0
+	for i, name := range names {
+		if i > 0 && names[i-1] >= name {
+			t.Errorf("Names is not sorted: %s before %s", names[i-1], name)
+		}
+		if name == "xs" || name == "pair" || name == "def" {
+			found++
+		}
+	}
+	if found != 3 {
+		t.Errorf("Names returned %v, actually expected xs, pair and def among them", names)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+
+	funcs := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"go-add", func(a, b int64) int64 { return a + b }},
+		{"go-repeat", func(s string, n int) string { return strings.Repeat(s, n) }},
+		{"go-not", func(b bool) bool { return !b }},
+		{"go-sum", func(xs ...float64) float64 {
+			sum := 0.0
+			for _, x := range xs {
+				sum += x
+			}
+			return sum
+		}},
+		{"go-lengths", func(xs []string) map[string]int {
+			m := make(map[string]int)
+			for _, x := range xs {
+				m[x] = len(x)
+			}
+			return m
+		}},
+		{"go-keys", func(m map[string]int64) []string {
+			var keys []string
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return keys
+		}},
+		{"go-sqrt", func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, errors.New("negative input")
+			}
+			return math.Sqrt(x), nil
+		}},
+		{"go-check", func(x int8) error {
+			if x == 0 {
+				return fmt.Errorf("zero is not allowed")
+			}
+			return nil
+		}},
+		{"go-big", func(x *big.Int) *big.Rat { return new(big.Rat).SetFrac(big.NewInt(1), x) }},
+		{"go-kind", func(x interface{}) string { return x.(Value).Kind().String() }},
+		{"go-uint", func(x uint8) uint64 { return uint64(x) << 56 }},
+	}
+	for _, f := range funcs {
+		if err := l.RegisterFunc(f.name, f.fn); err != nil {
+			t.Fatalf("RegisterFunc %s returned error: %v", f.name, err)
+		}
+	}
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"go-add 1 2", "3"},
+		{"go-add 1", "Error: Function 'go-add' passed too few arguments: (1)"},
+		{"go-add 1 2 3", "Error: Function 'go-add' passed too many arguments: (1 2 3)"},
+		{"go-add 1 2.0", "Error: Function 'go-add' passed incorrect type for argument 2: expected int64, got Float"},
+		{"go-repeat \"ab\" 3", "\"ababab\""},
+		{"go-not #f", "#t"},
+		{"go-sum 0.5", "0.5"},
+		{"go-sum 1 1/2 0.25", "1.75"},
+		{"go-lengths {\"a\" \"abc\"}", "#{\"a\" 1 \"abc\" 3}"},
+		{"go-keys #{\"y\" 1 \"x\" 2}", "{\"x\" \"y\"}"},
+		{"go-keys #{\"y\" 1.5}", "Error: Function 'go-keys' passed incorrect type for argument 1: expected int64, got Float"},
+		{"go-sqrt 16", "4.0"},
+		{"go-sqrt -1", "Error: negative input"},
+		{"go-check 1", "()"},
+		{"go-check 0", "Error: zero is not allowed"},
+		{"go-check 300", "Error: Function 'go-check' passed incorrect type for argument 1: 300 is out of range for int8"},
+		{"go-big 3", "1/3"},
+		{"go-kind {a}", "\"Q-Expression\""},
+		{"go-uint 255", "18374686479671623680"},
+		{"go-uint -1", "Error: Function 'go-uint' passed incorrect type for argument 1: -1 is out of range for uint8"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+
+	invalid := []interface{}{
+		42,
+		func(c chan int) {},
+		func() (int, int) { return 0, 0 },
+		func() *int { return nil },
+		nil,
+		(func(int) int)(nil),
+	}
+	for _, fn := range invalid {
+		if err := l.RegisterFunc("bad", fn); err == nil {
+			t.Errorf("RegisterFunc of %T returned no error", fn)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	ctx := context.Background()
+
+	got, err := l.Eval(ctx, "* 6 7")
+	if err != nil || got.String() != "42" {
+		t.Errorf("Eval returned %s, %v, actually expected 42, nil", got, err)
+	}
+
+	_, err = l.Eval(ctx, "+ 1 (")
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Eval of bad syntax returned %v, actually expected a *ParseError", err)
+	}
+	if perr.File != "<eval>" || perr.Line != 1 || perr.Col != 6 {
+		t.Errorf("Eval returned parse error at %s:%d:%d, actually expected <eval>:1:6", perr.File, perr.Line, perr.Col)
+	}
+
+	l.ReadEval("def {f} (\\ {x} {head x})", false)
+	_, err = l.Eval(ctx, "f {}")
+	var eerr *EvalError
+	if !errors.As(err, &eerr) {
+		t.Fatalf("Eval of failing code returned %v, actually expected an *EvalError", err)
+	}
+	if eerr.Msg != "Function 'head' passed {}!" {
+		t.Errorf("Eval returned error message %q", eerr.Msg)
+	}
+	if len(eerr.Trace) != 2 || eerr.Trace[0] != "at head (<stdin>:1:16)" || eerr.Trace[1] != "at f (<eval>:1:1)" {
+		t.Errorf("Eval returned error trace %q", eerr.Trace)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := l.Eval(cancelled, "+ 1 2"); !errors.As(err, &eerr) || !errors.Is(err, ErrCancelled) {
+		t.Errorf("Eval with a cancelled context returned %v, actually expected %v", err, ErrCancelled)
+	}
+	// The interpreter is left usable afterwards
+	if got, err := l.Eval(ctx, "+ 1 2"); err != nil || got.String() != "3" {
+		t.Errorf("Eval after a cancelled context returned %s, %v, actually expected 3, nil", got, err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.lspy")
+	if err := os.WriteFile(good, []byte("(def {a} 1)\n(def {b} (+ a 1))\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.LoadFile(good); err != nil {
+		t.Errorf("LoadFile returned %v, actually expected nil", err)
+	}
+	if got := l.ReadEval("b", false).lvalString(); got != "2" {
+		t.Errorf("LoadFile defined b as %s, actually expected 2", got)
+	}
+
+	// Loading stops at the first error
+	bad := filepath.Join(dir, "bad.lspy")
+	if err := os.WriteFile(bad, []byte("(def {c} 1)\n(error \"stop\")\n(def {d} 1)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := l.LoadFile(bad)
+	var eerr *EvalError
+	if !errors.As(err, &eerr) || err.Error() != bad+":2:1: stop" {
+		t.Errorf("LoadFile returned %v, actually expected an *EvalError at %s:2:1", err, bad)
+	}
+	if got := l.ReadEval("d", false).lvalString(); got != "Error: Unbound Symbol: 'd'" {
+		t.Errorf("LoadFile carried on past an error and defined d as %s", got)
+	}
+
+	unparsable := filepath.Join(dir, "unparsable.lspy")
+	if err := os.WriteFile(unparsable, []byte("(def {e} 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var perr *ParseError
+	if err := l.LoadFile(unparsable); !errors.As(err, &perr) || perr.Line != 2 {
+		t.Errorf("LoadFile returned %v, actually expected a *ParseError on line 2", err)
+	}
+
+	if err := l.LoadFile(filepath.Join(dir, "missing.lspy")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFile of a missing file returned %v, actually expected %v", err, os.ErrNotExist)
+	}
+	// The load builtin reports the error rather than printing it
+	if got := l.ReadEval("load \""+bad+"\"", false).lvalString(); got != "Error: stop" {
+		t.Errorf("load returned %s, actually expected Error: stop", got)
+	}
+
+	// Source that is not read from a file
+	if err := l.LoadSource("<string>", "(def {f} 1)\n(error \"stop\")"); err == nil || err.Error() != "<string>:2:1: stop" {
+		t.Errorf("LoadSource returned %v, actually expected an *EvalError at <string>:2:1", err)
+	}
+	if err := l.LoadPrelude(); err != nil {
+		t.Errorf("LoadPrelude returned %v, actually expected nil", err)
+	}
+	if got := l.ReadEval("day-name 2", false).lvalString(); got != "\"Wednesday\"" {
+		t.Errorf("LoadPrelude defined day-name 2 as %s, actually expected \"Wednesday\"", got)
+	}
+}
+
+func TestRedirectedIO(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	var stdout, stderr bytes.Buffer
+	l.SetStdout(&stdout)
+	l.SetStderr(&stderr)
+	l.SetStdin(strings.NewReader("first line\r\nlast line"))
+
+	l.ReadEvalPrint("print 1 \"two\" {3}")
+	l.ReadEvalPrint("+ 1 2")
+	l.ReadEvalPrint("head {}")
+	l.ReadEvalPrint("(")
+	l.PrintAst("1")
+	if want := "1 \"two\" {3} \n()\n3\n> \n  number:1:1 '1'\n"; stdout.String() != want {
+		t.Errorf("Wrote to stdout: %q, actually expected: %q", stdout.String(), want)
+	}
+	if want := "<stdin>:1:1: Error: Function 'head' passed {}!\n  at head (<stdin>:1:1)\n" +
+		"<stdin>:1:2: error: expected ')' to close '(' at 1:1\n" +
+		"<stdin>:1:2: Error: Failed to parse input: '('\n"; stderr.String() != want {
+		t.Errorf("Wrote to stderr: %q, actually expected: %q", stderr.String(), want)
+	}
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"read-line ()", "\"first line\""},
+		{"read-line ()", "\"last line\""},
+		{"read-line ()", "Error: read-line reached the end of input"},
+		{"read-line 1", "Error: read-line passed (1), expected ()"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("def {loop} (\\ {n} {loop (+ n 1)})", false)
+	l.ReadEval("def {fib} (\\ {n} {if (< n 2) {n} {+ (fib (- n 1)) (fib (- n 2))}})", false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := l.Eval(ctx, "loop 0")
+	var eerr *EvalError
+	if !errors.As(err, &eerr) || !errors.Is(err, ErrCancelled) {
+		t.Fatalf("Eval of an endless loop returned %v, actually expected %v", err, ErrCancelled)
+	}
+	if eerr.Msg != "Evaluation cancelled" {
+		t.Errorf("Eval returned error message %q, actually expected \"Evaluation cancelled\"", eerr.Msg)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := l.Eval(ctx, "fib 100"); !errors.Is(err, ErrCancelled) {
+		t.Errorf("Eval of deep recursion returned %v, actually expected %v", err, ErrCancelled)
+	}
+
+	// Errors raised by Lispy code are not cancellations
+	if _, err := l.Eval(context.Background(), "error \"stop\""); err == nil || errors.Is(err, ErrCancelled) {
+		t.Errorf("Eval of error returned %v, actually expected a plain error", err)
+	}
+	// The interpreter can be used again once evaluation is cancelled
+	if got, err := l.Eval(context.Background(), "fib 10"); err != nil || got.String() != "55" {
+		t.Errorf("Eval after cancellation returned %s, %v, actually expected 55, nil", got, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("def {loop} (\\ {n} {loop (+ n 1)})", false)
+	l.ReadEval("def {down} (\\ {n} {if (== n 0) {0} {+ 1 (down (- n 1))}})", false)
+	l.ReadEval("def {grow} (\\ {xs} {grow (join xs xs)})", false)
+	l.RegisterFunc("repeat", strings.Repeat)
+	l.SetLimits(Limits{MaxSteps: 10000, MaxDepth: 100, MaxCells: 1000, MaxStringBytes: 64, MaxNumberBits: 1000})
+	ctx := context.Background()
+
+	cases := []struct {
+		input string
+		cause error
+		want  string
+	}{
+		{"down 20", nil, "20"},
+		{"loop 0", ErrSteps, "Step limit of 10000 exceeded"},
+		{"down 1000", ErrDepth, "Call depth limit of 100 exceeded"},
+		{"grow {1}", ErrCells, "Cell limit of 1000 exceeded"},
+		{"repeat \"ab\" 32", nil, "\"" + strings.Repeat("ab", 32) + "\""},
+		{"repeat \"ab\" 33", ErrStringBytes, "String limit of 64 bytes exceeded"},
+		{"^ 2 999", nil, new(big.Int).Lsh(big.NewInt(1), 999).String()},
+		{"^ 3 30000000", ErrNumberBits, "Number limit of 1000 bits exceeded"},
+		{"^ 2 (^ 2 40)", ErrNumberBits, "Number limit of 1000 bits exceeded"},
+		{"^ (/ 1 3) 1000", ErrNumberBits, "Number limit of 1000 bits exceeded"},
+		{"* (^ 2 999) 2", ErrNumberBits, "Number limit of 1000 bits exceeded"},
+		// Each evaluation has a budget of its own
+		{"+ 1 2", nil, "3"},
+	}
+
+	for _, c := range cases {
+		got, err := l.Eval(ctx, c.input)
+		if c.cause == nil {
+			if err != nil || got.String() != c.want {
+				t.Errorf("Eval input: \"%s\" returned: %s, %v, actually expected: %s", c.input, got, err, c.want)
+			}
+			continue
+		}
+		var eerr *EvalError
+		if !errors.As(err, &eerr) || !errors.Is(err, c.cause) || eerr.Msg != c.want {
+			t.Errorf("Eval input: \"%s\" returned error: %v, actually expected: %s", c.input, err, c.want)
+		}
+	}
+
+	// Without limits, the same code runs to completion
+	l.SetLimits(Limits{})
+	if got, err := l.Eval(ctx, "down 1000"); err != nil || got.String() != "1000" {
+		t.Errorf("Eval without limits returned %s, %v, actually expected 1000, nil", got, err)
+	}
+
+	// Walking a list does not charge its cells again at every step
+	if err := l.LoadPrelude(); err != nil {
+		t.Fatal(err)
+	}
+	l.ReadEval("def {xs} {"+strings.Repeat("1 ", 600)+"}", false)
+	l.SetLimits(Limits{MaxCells: 100000})
+	if got, err := l.Eval(ctx, "len xs"); err != nil || got.String() != "600" {
+		t.Errorf("Eval of len under a cell limit returned %s, %v, actually expected 600, nil", got, err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	sandbox := InitLispyWithCapabilities(Capabilities{})
+	defer CleanLispy(sandbox)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"+ 1 2", "3"},
+		{"load \"prelude.lspy\"", "Error: Unbound Symbol: 'load'"},
+		{"print \"hi\"", "Error: Unbound Symbol: 'print'"},
+		{"read-line ()", "Error: Unbound Symbol: 'read-line'"},
+		{"error \"still raised\"", "Error: still raised"},
+	}
+
+	for _, c := range cases {
+		got := sandbox.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+
+	fsys := fstest.MapFS{
+		"lib/double.lspy": {Data: []byte("(def {double} (\\ {x} {* 2 x}))\n")},
+	}
+	l := InitLispyWithCapabilities(Capabilities{Load: true, FS: fsys, Print: true})
+	defer CleanLispy(l)
+	var stdout bytes.Buffer
+	l.SetStdout(&stdout)
+
+	cases = []struct {
+		input string
+		want  string
+	}{
+		{"load \"lib/double.lspy\"", "()"},
+		{"double 21", "42"},
+		{"load \"prelude.lspy\"", "Error: Could not load library open prelude.lspy: file does not exist"},
+		{"load \"../lispy/prelude.lspy\"", "Error: Could not load library open ../lispy/prelude.lspy: invalid argument"},
+		{"print (double 2)", "()"},
+		{"read-line ()", "Error: Unbound Symbol: 'read-line'"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+	if stdout.String() != "4 \n" {
+		t.Errorf("Wrote to stdout: %q, actually expected: \"4 \\n\"", stdout.String())
+	}
+	if err := l.LoadFile("lispy_test.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadFile outside of the FS returned %v, actually expected %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		// Returned closures keep the environment they were made in
+		{"fun {adder n} {\\ {x} {+ x n}}", "()"},
+		{"def {add5} (adder 5)", "()"},
+		{"add5 10", "15"},
+		{"def {n} 100", "()"},
+		{"add5 1", "6"},
+		{"fun {compose f g} {\\ {x} {f (g x)}}", "()"},
+		{"def {add3} (compose (adder 1) (adder 2))", "()"},
+		{"add3 3", "6"},
+		// Partially applied functions keep their definition environment
+		{"def {sum3} (\\ {a b c} {+ a b c})", "()"},
+		{"def {part} (sum3 1)", "()"},
+		{"def {a} 100", "()"},
+		{"part 2 3", "6"},
+		// Callers' variables are not visible to the functions they call
+		{"fun {get-y _} {y}", "()"},
+		{"fun {call-with-y y} {get-y ()}", "()"},
+		{"call-with-y 5", "Error: Unbound Symbol: 'y'"},
+		// Shadowing
+		{"def {x} 1", "()"},
+		{"fun {shadow x} {+ x 10}", "()"},
+		{"shadow 5", "15"},
+		{"x", "1"},
+		{"(\\ {x} {(\\ {x} {* x 3}) (+ x 1)}) 1", "6"},
+		{"(\\ {x} {(\\ {y} {+ x y}) 10}) 1", "11"},
+		{"def {b} 7", "()"},
+		{"fun {use-b x} {+ x b}", "()"},
+		{"use-b 1", "8"},
+		// Scopes opened in a function see its variables
+		{"fun {let-test y} {let {do (= {z} 2) (+ y z)}}", "()"},
+		{"let-test 40", "42"},
+		{"z", "Error: Unbound Symbol: 'z'"},
+		{"fun {pick v} {select {(== v 1) \"one\"} {otherwise v}}", "()"},
+		{"pick 1", "\"one\""},
+		{"pick 2", "2"},
+		{"fun {name v} {case v {1 \"one\"} {2 (+ v v)}}", "()"},
+		{"name 2", "4"},
+		{"name 3", "Error: No case found!"},
+		// The value a case is on is worked out once, not once per clause
+		{"def {hits} 0", "()"},
+		{"case (do (set! {hits} (+ hits 1)) 2) {1 \"a\"} {2 \"b\"}", "\"b\""},
+		{"hits", "1"},
+		// Clauses see the caller's variables, whatever their names
+		{"fun {f _case} {case 1 {1 _case}}", "()"},
+		{"f 42", "42"},
+		{"fun {g x} {do (case x {1 (= {y} 5)}) y}", "()"},
+		{"g 1", "5"},
+		{"case 1 2", "Error: case clause is not a Q-exp starting with a key: 2"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestMutation(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"def {x} 1", "()"},
+		{"set! {x} 2", "()"},
+		{"x", "2"},
+		{"set! {undefined} 1", "Error: Unbound Symbol: 'undefined'"},
+		{"set! {x} 3 4", "Error: Function set! cannot define incorrect number of values to symbols"},
+		// set! changes the nearest binding, where = makes a new local one
+		{"fun {set-local x} {do (set! {x} 10) x}", "()"},
+		{"set-local 5", "10"},
+		{"x", "2"},
+		{"fun {set-global _} {set! {x} 20}", "()"},
+		{"set-global ()", "()"},
+		{"x", "20"},
+		{"fun {put-local _} {do (= {x} 30) x}", "()"},
+		{"put-local ()", "30"},
+		{"x", "20"},
+		// Closures share the environment they capture
+		{"fun {make-counter n} {\\ {_} {do (set! {n} (+ n 1)) n}}", "()"},
+		{"def {c1} (make-counter 0)", "()"},
+		{"def {c2} (make-counter 100)", "()"},
+		{"c1 ()", "1"},
+		{"c1 ()", "2"},
+		{"c2 ()", "101"},
+		{"c1 ()", "3"},
+		{"fun {make-account balance} {list (\\ {n} {set! {balance} (+ balance n)}) (\\ {_} {balance})}", "()"},
+		{"def {account} (make-account 10)", "()"},
+		{"(fst account) 5", "()"},
+		{"(snd account) ()", "15"},
+		// Calls of the same function do not share their arguments
+		{"def {add} (\\ {a b} {+ a b})", "()"},
+		{"def {add1} (add 1)", "()"},
+		{"add1 2", "3"},
+		{"add1 5", "6"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	l.ReadEval("load \"prelude.lspy\"", false) // Load standard library
+	var stdout bytes.Buffer
+	l.SetStdout(&stdout)
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"try {+ 1 2} {catch e 0}", "3"},
+		{"try {head {}} {catch e (error-message e)}", "\"Function \\'head\\' passed {}!\""},
+		{"try {head {}} {catch e e}", "<error: Function 'head' passed {}!>"},
+		{"try {head {}} {catch e (error? e)}", "#t"},
+		{"error? 1", "#f"},
+		{"try {error \"50% done\"} {catch e (error-message e)}", "\"50% done\""},
+		{"try {case 9 {0 \"zero\"}} {catch e (error-message e)}", "\"No case found!\""},
+		{"try {select {#f 1}} {catch e (error-message e)}", "\"No selection found!\""},
+		{"select {#f 1} {#f 2}", "Error: No selection found!"},
+		{"select {#f 1} {#t 2}", "2"},
+		// Any value can be thrown
+		{"throw {1 2}", "Error: {1 2}"},
+		{"throw \"plain\"", "Error: plain"},
+		{"try {throw {1 2}} {catch e (error-value e)}", "{1 2}"},
+		{"try {throw #{\"k\" 1}} {catch e (get (error-value e) \"k\")}", "1"},
+		{"try {error \"msg\"} {catch e (error-value e)}", "\"msg\""},
+		// Errors raised inside functions are caught by their callers
+		{"fun {risky x} {if (> x 0) {x} {throw x}}", "()"},
+		{"fun {safe x} {try {risky x} {catch e (* -1 (error-value e))}}", "()"},
+		{"safe 5", "5"},
+		{"safe -5", "5"},
+		{"map safe {1 -2 3}", "{1 2 3}"},
+		// Caught errors can be thrown again
+		{"try {try {throw 1} {catch e (throw e)}} {catch e (+ 1 (error-value e))}", "2"},
+		{"try {throw 1} {catch e (throw \"other\")}", "Error: other"},
+		// Cleaning up
+		{"try {+ 1 2} {finally print \"cleanup 1\"}", "3"},
+		{"try {throw 1} {finally print \"cleanup 2\"}", "Error: 1"},
+		{"try {throw 1} {catch e \"caught\"} {finally print \"cleanup 3\"}", "\"caught\""},
+		{"try {+ 1 2} {finally error \"in cleanup\"}", "Error: in cleanup"},
+		{"try {def {tried} 1} {catch e 0} {finally def {cleaned} 2}", "()"},
+		{"+ tried cleaned", "3"},
+		// The handler sees its caller's variables, and the error only in itself
+		{"fun {wrap x} {try {throw x} {catch e (list x (error-value e))}}", "()"},
+		{"wrap 7", "{7 7}"},
+		{"e", "Error: Unbound Symbol: 'e'"},
+		// Malformed forms
+		{"try {1}", "Error: try passed in 1 args, expected a body and catch or finally clauses"},
+		{"try {1} {rescue e 0}", "Error: try clause does not start with catch or finally: {rescue e 0}"},
+		{"try {1} {catch 0}", "Error: catch is not followed by a symbol: {0}"},
+		{"try {head {}} {catch e}", "Error: catch is not followed by a handler: {catch e}"},
+		{"try {1} 2", "Error: Function 'try' passed incorrect type: Number"},
+		{"error-message 1", "Error: error-message passed non-error: Number"},
+	}
+
+	for _, c := range cases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+	if want := "\"cleanup 1\" \n\"cleanup 2\" \n\"cleanup 3\" \n"; stdout.String() != want {
+		t.Errorf("Wrote to stdout: %q, actually expected: %q", stdout.String(), want)
+	}
+
+	// Values thrown out to Go are kept on the error
+	_, err := l.Eval(context.Background(), "throw {1 2}")
+	var eerr *EvalError
+	if !errors.As(err, &eerr) || eerr.Value.String() != "{1 2}" {
+		t.Errorf("Eval of throw returned %v, actually expected an *EvalError with value {1 2}", err)
+	}
+	// Running over a limit cannot be caught
+	l.SetLimits(Limits{MaxSteps: 1000})
+	l.ReadEval("def {loop} (\\ {n} {loop (+ n 1)})", false)
+	if _, err := l.Eval(context.Background(), "try {loop 0} {catch e 0}"); !errors.Is(err, ErrSteps) {
+		t.Errorf("Eval of try around an endless loop returned %v, actually expected %v", err, ErrSteps)
+	}
+}
+
+func TestExit(t *testing.T) {
+	l := InitLispy()
+	defer CleanLispy(l)
+	var stdout, stderr bytes.Buffer
+	l.SetStdout(&stdout)
+	l.SetStderr(&stderr)
+
+	cases := []struct {
+		input string
+		code  int
+	}{
+		{"exit 0", 0},
+		{"exit 3", 3},
+		{"if #t {exit 255} {0}", 255},
+		// Exiting cannot be caught, but cleans up on the way out
+		{"try {exit 4} {catch e 0}", 4},
+		{"try {exit 5} {finally print \"cleanup\"}", 5},
+	}
+
+	for _, c := range cases {
+		_, err := l.Eval(context.Background(), c.input)
+		var exit *ExitError
+		if !errors.As(err, &exit) || exit.Code != c.code {
+			t.Errorf("Eval input: \"%s\" returned: %v, actually expected exit status %d", c.input, err, c.code)
+		}
+	}
+
+	errCases := []struct {
+		input, want string
+	}{
+		{"exit 256", "Error: exit passed 256, expected a status from 0 to 255"},
+		{"exit -1", "Error: exit passed -1, expected a status from 0 to 255"},
+		{"exit \"1\"", "Error: exit passed \"1\", expected a status from 0 to 255"},
+		{"exit 1 2", "Error: exit passed in 2 args, not 1"},
+	}
+	for _, c := range errCases {
+		got := l.ReadEval(c.input, false)
+		if got.lvalString() != c.want {
+			t.Errorf("ReadEval input: \"%s\" returned: \"%s\", actually expected: \"%s\"", c.input, got.lvalString(), c.want)
+		}
+	}
+
+	// Exiting is returned by ReadEvalPrint rather than printed
+	stdout.Reset()
+	stderr.Reset()
+	err := l.ReadEvalPrint("exit 6")
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 6 || stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Errorf("ReadEvalPrint of exit returned: %v and wrote: %q and %q, actually expected exit status 6 and nothing written",
+			err, stdout.String(), stderr.String())
+	}
+	if err := l.ReadEvalPrint("head {}"); err == nil || stderr.String() != "<stdin>:1:1: Error: Function 'head' passed {}!\n  at head (<stdin>:1:1)\n" {
+		t.Errorf("ReadEvalPrint of an error returned: %v and wrote: %q", err, stderr.String())
+	}
+}
@@ -2,40 +2,90 @@ package lispy
 
 import (
 	"fmt"
+	"io"
+	"math/big"
 	"strconv"
-	"strings"
-
-	"github.com/sunzenshen/go-build-your-own-lisp/mpc"
 )
 
 // ltype values for lval
 const (
 	lvalNumType = iota
+	lvalRatType
+	lvalFloatType
+	lvalBoolType
 	lvalSymType
 	lvalStrType
 	lvalFunType
 	lvalSexprType
 	lvalQexprType
+	lvalMapType
 	lvalErrType
+	lvalCondType  // An error caught by try, which is passed around like any other value
+	lvalThunkType // Internal: an expression left for lvalEval to continue with
 )
 
 type lval struct {
 	ltype int
 
 	// Basic
-	num int64  // lvalNumType
-	err string // lvalErrType
-	sym string // lvalSymType
-	str string // lvalStrType
+	num     int64    // lvalNumType
+	bnum    *big.Int // lvalNumType, nil unless num cannot hold the value
+	rat     *big.Rat // lvalRatType
+	fnum    float64  // lvalFloatType
+	boolean bool     // lvalBoolType
+	err     string   // lvalErrType, lvalCondType
+	cause   error    // lvalErrType, set when evaluation was stopped from outside
+	thrown  *lval    // lvalErrType, lvalCondType, the value passed to throw
+	sym     string   // lvalSymType
+	str     string   // lvalStrType
 
 	// Function
 	builtin lbuiltin // lvalFunType, nil for user defined function
-	env     *lenv
+	special bool     // lvalFunType, builtin is passed its arguments unevaluated
+	macro   bool     // lvalFunType, user defined function returning code to evaluate
+	env     *lenv    // lvalFunType, lvalThunkType
 	formals *lval
-	body    *lval
+	body    *lval // lvalFunType, lvalThunkType
 
 	// Expression
 	cells []*lval // lvalSexprType, lvalQexprType
+
+	// Map, keyed by lvalMapKey of each entry's key. It is nil for a map
+	// literal, which holds its key and value forms in cells, in the order
+	// they were read.
+	entries map[string]lentry // lvalMapType
+
+	// Call stack an lvalErrType or lvalCondType passed through, innermost
+	// call first
+	trace []lframe
+
+	// Source position, nil unless produced by the reader or raised while
+	// evaluating something that was
+	pos *lpos
+}
+
+// lpos is the place in the source text an lval was read from
+type lpos struct {
+	file string
+	line int
+	col  int
+}
+
+func (p *lpos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.file, p.line, p.col)
+}
+
+// lframe is a function call an error propagated through
+type lframe struct {
+	name string
+	pos  *lpos
+}
+
+func (f lframe) String() string {
+	if f.pos == nil {
+		return "at " + f.name
+	}
+	return "at " + f.name + " (" + f.pos.String() + ")"
 }
 
 // lvalNum creates an lval number
@@ -46,6 +96,48 @@ func lvalNum(x int64) *lval {
 	return v
 }
 
+// lvalBigNum creates an lval number from a big.Int, which must not be
+// modified afterwards
+func lvalBigNum(x *big.Int) *lval {
+	// Keep values that fit in an int64 in their small form
+	if x.IsInt64() {
+		return lvalNum(x.Int64())
+	}
+	v := new(lval)
+	v.ltype = lvalNumType
+	v.bnum = x
+	return v
+}
+
+// lvalRat creates an lval rational number from a big.Rat, which must not be
+// modified afterwards
+func lvalRat(x *big.Rat) *lval {
+	// Whole numbers are kept as integers
+	if x.IsInt() {
+		return lvalBigNum(new(big.Int).Set(x.Num()))
+	}
+	v := new(lval)
+	v.ltype = lvalRatType
+	v.rat = x
+	return v
+}
+
+// lvalFloat creates an lval floating-point number
+func lvalFloat(x float64) *lval {
+	v := new(lval)
+	v.ltype = lvalFloatType
+	v.fnum = x
+	return v
+}
+
+// lvalBool creates an lval boolean
+func lvalBool(b bool) *lval {
+	v := new(lval)
+	v.ltype = lvalBoolType
+	v.boolean = b
+	return v
+}
+
 // lvalErr creates an lval error
 func lvalErr(f string, a ...interface{}) *lval {
 	v := new(lval)
@@ -54,6 +146,26 @@ func lvalErr(f string, a ...interface{}) *lval {
 	return v
 }
 
+// lvalThrown creates an lval error carrying any value, whose message is
+// the value printed, or the contents of a string
+func lvalThrown(x *lval) *lval {
+	if x.ltype == lvalStrType {
+		v := lvalErr("%s", x.str)
+		v.thrown = x
+		return v
+	}
+	v := lvalErr("%s", x.lvalString())
+	v.thrown = x
+	return v
+}
+
+// lvalCondition turns a caught error into a condition, which can be passed
+// around without being raised again
+func lvalCondition(x *lval) *lval {
+	x.ltype = lvalCondType
+	return x
+}
+
 // lvalSym creates an lval symbol
 func lvalSym(s string) *lval {
 	v := new(lval)
@@ -94,20 +206,42 @@ func lvalFun(function lbuiltin) *lval {
 	return v
 }
 
-// lvalLambda creates a user defined lval function
-func lvalLambda(formals *lval, body *lval) *lval {
+// lvalSpecial creates a special form: a builtin function that receives its
+// arguments unevaluated and decides itself which of them to evaluate
+func lvalSpecial(function lbuiltin) *lval {
+	v := lvalFun(function)
+	v.special = true
+	return v
+}
+
+// lvalLambda creates a user defined lval function, which sees the symbols
+// of the environment e it is defined in
+func lvalLambda(e *lenv, formals *lval, body *lval) *lval {
 	v := new(lval)
 	v.ltype = lvalFunType
 	// Builtin is nil for user defined functions
 	v.builtin = nil
-	// Init environment
+	// Init environment, whose parent is the defining environment so that
+	// free variables are resolved lexically
 	v.env = lenvNew()
+	v.env.par = e
 	// Set formals and body
 	v.formals = formals
 	v.body = body
 	return v
 }
 
+// lvalThunk creates a tail call: body still has to be evaluated in env.
+// Builtins return thunks instead of evaluating in place so that lvalEval
+// can run calls in tail position without growing the Go stack.
+func lvalThunk(body *lval, env *lenv) *lval {
+	v := new(lval)
+	v.ltype = lvalThunkType
+	v.body = body
+	v.env = env
+	return v
+}
+
 func (v *lval) cellCount() int {
 	return len(v.cells)
 }
@@ -120,8 +254,16 @@ func ltypeName(i int) string {
 	switch i {
 	case lvalNumType:
 		return "Number"
+	case lvalRatType:
+		return "Rational"
+	case lvalFloatType:
+		return "Float"
+	case lvalBoolType:
+		return "Boolean"
 	case lvalErrType:
 		return "Error"
+	case lvalCondType:
+		return "Condition"
 	case lvalSymType:
 		return "Symbol"
 	case lvalStrType:
@@ -132,6 +274,10 @@ func ltypeName(i int) string {
 		return "S-Expression"
 	case lvalQexprType:
 		return "Q-Expression"
+	case lvalMapType:
+		return "Map"
+	case lvalThunkType:
+		return "Thunk"
 	}
 	return "Unknown:" + strconv.Itoa(i)
 }
@@ -139,15 +285,32 @@ func ltypeName(i int) string {
 func (v *lval) lvalString() string {
 	switch v.ltype {
 	case lvalNumType:
+		if v.bnum != nil {
+			return v.bnum.String()
+		}
 		return strconv.FormatInt(v.num, 10)
+	case lvalRatType:
+		return v.rat.RatString()
+	case lvalFloatType:
+		return formatFloat(v.fnum)
+	case lvalBoolType:
+		if v.boolean {
+			return "#t"
+		}
+		return "#f"
 	case lvalErrType:
 		return ("Error: " + v.err)
+	case lvalCondType:
+		return "<error: " + v.err + ">"
 	case lvalSymType:
 		return (v.sym)
 	case lvalStrType:
 		return v.lvalGetStr()
 	case lvalFunType:
 		if v.builtin == nil {
+			if v.macro {
+				return "(macro " + v.formals.lvalString() + " " + v.body.lvalString() + ")"
+			}
 			return "(\\ " + v.formals.lvalString() + " " + v.body.lvalString() + ")"
 		}
 		return "<builtin>"
@@ -155,6 +318,8 @@ func (v *lval) lvalString() string {
 		return v.lvalExprString("(", ")")
 	case lvalQexprType:
 		return v.lvalExprString("{", "}")
+	case lvalMapType:
+		return v.lvalMapString()
 	}
 	return fmt.Sprintf("Error: lvalString() unhandled ltype %d", v.ltype)
 }
@@ -163,44 +328,64 @@ func (v *lval) lvalGetStr() string {
 	// Make a copy of the string
 	escaped := string(v.str)
 	// Pass it through the escape function
-	escaped = mpc.MpcfEscape(escaped)
+	escaped = escape(escaped)
 	// Enclose the result between " characters
 	return "\"" + escaped + "\""
 }
 
-func lvalReadStr(t mpc.AstPtr) *lval {
-	contents := mpc.GetContents(t)
-	unescaped := contents[1 : len(contents)-1] // Cut off quote characters
-	unescaped = mpc.MpcfUnescape(unescaped)
-	return lvalStr(unescaped)
-}
-
-func (v *lval) lvalPrint() {
-	fmt.Print(v.lvalString())
+func (v *lval) lvalPrint(w io.Writer) {
+	// Errors are prefixed with where they were raised
+	if v.ltype == lvalErrType && v.pos != nil {
+		fmt.Fprint(w, v.pos.String()+": ")
+	}
+	fmt.Fprint(w, v.lvalString())
 }
 
-func (v *lval) lvalPrintLn() {
-	v.lvalPrint()
-	fmt.Print("\n")
+func (v *lval) lvalPrintLn(w io.Writer) {
+	v.lvalPrint(w)
+	fmt.Fprint(w, "\n")
+	// Follow errors with the calls they were raised through
+	if v.ltype == lvalErrType {
+		for _, frame := range v.trace {
+			fmt.Fprintln(w, "  "+frame.String())
+		}
+	}
 }
 
 func lvalCopy(v *lval) *lval {
 	x := new(lval)
 	x.ltype = v.ltype
+	x.pos = v.pos
 	switch v.ltype {
 	case lvalFunType:
 		if v.builtin == nil {
 			x.builtin = nil
-			x.env = lenvCopy(v.env)
+			x.macro = v.macro
+			// Environments are shared, so that closures see changes
+			// made through set!
+			x.env = v.env
 			x.formals = lvalCopy(v.formals)
 			x.body = lvalCopy(v.body)
 		} else {
 			x.builtin = v.builtin
+			x.special = v.special
 		}
 	case lvalNumType:
 		x.num = v.num
-	case lvalErrType:
+		x.bnum = v.bnum
+	case lvalRatType:
+		x.rat = v.rat
+	case lvalFloatType:
+		x.fnum = v.fnum
+	case lvalBoolType:
+		x.boolean = v.boolean
+	case lvalErrType, lvalCondType:
 		x.err = string(v.err)
+		x.cause = v.cause
+		if v.thrown != nil {
+			x.thrown = lvalCopy(v.thrown)
+		}
+		x.trace = append([]lframe(nil), v.trace...)
 	case lvalSymType:
 		x.sym = string(v.sym)
 	case lvalStrType:
@@ -211,10 +396,40 @@ func lvalCopy(v *lval) *lval {
 		for _, cell := range v.cells {
 			x.cells = append(x.cells, lvalCopy(cell))
 		}
+	case lvalMapType:
+		if v.entries == nil {
+			for _, cell := range v.cells {
+				x.cells = append(x.cells, lvalCopy(cell))
+			}
+			break
+		}
+		x.entries = make(map[string]lentry, len(v.entries))
+		for k, entry := range v.entries {
+			x.entries[k] = lentry{lvalCopy(entry.key), lvalCopy(entry.val)}
+		}
 	}
 	return x
 }
 
+// lvalSlice creates a list of type ltype holding cells, which it shares with
+// the list they were taken from. Lists are not changed once they are built,
+// so the sharing cannot be seen, and the capacity is cut so that adding to
+// the new list never writes into the old one.
+func lvalSlice(ltype int, cells []*lval) *lval {
+	v := new(lval)
+	v.ltype = ltype
+	v.cells = cells[:len(cells):len(cells)]
+	return v
+}
+
+// lvalSexprOf returns the cells of the list x as an S-expression to be
+// evaluated, leaving x as it is
+func lvalSexprOf(x *lval) *lval {
+	v := lvalSlice(lvalSexprType, x.cells)
+	v.pos = x.pos
+	return v
+}
+
 func lvalAdd(v *lval, x *lval) *lval {
 	v.cells = append(v.cells, x)
 	return v
@@ -232,92 +447,138 @@ func (v *lval) lvalExprString(openChar string, closeChar string) string {
 	return s
 }
 
-func (v *lval) lvalExprPrint(openChar string, closeChar string) {
-	fmt.Print(v.lvalExprString(openChar, closeChar))
+func (v *lval) lvalExprPrint(w io.Writer, openChar string, closeChar string) {
+	fmt.Fprint(w, v.lvalExprString(openChar, closeChar))
 }
 
-func lvalReadNum(tree mpc.AstPtr) *lval {
-	x, err := strconv.ParseInt(mpc.GetContents(tree), 10, 0)
-	if err != nil {
-		return lvalErr("Invalid Number: %s", mpc.GetContents(tree))
+// lvalEvalSexpr evaluates an S-expression up to its outermost call. When
+// that call is in tail position an lvalThunkType is returned for lvalEval to
+// carry on with, recording the call in its trace. The expression itself is
+// left as it is, as it may be the body of a function called again.
+func (v *lval) lvalEvalSexpr(e *lenv) *lval {
+	// Empty Expression
+	if v.cellCount() == 0 {
+		return v
 	}
-	return lvalNum(x)
-}
-
-func lvalRead(tree mpc.AstPtr) *lval {
-	// If Symbol or Number, return conversion to that type
-	if strings.Contains(mpc.GetTag(tree), "number") {
-		return lvalReadNum(tree)
+	// Single Expression
+	if v.cellCount() == 1 {
+		return lvalThunk(v.cells[0], e)
 	}
-	if strings.Contains(mpc.GetTag(tree), "symbol") {
-		return lvalSym(mpc.GetContents(tree))
+	// Name the call for stack traces before the function is evaluated
+	name := "<lambda>"
+	if v.cells[0].ltype == lvalSymType {
+		name = v.cells[0].sym
 	}
-	if strings.Contains(mpc.GetTag(tree), "string") {
-		return lvalReadStr(tree)
+	// Evaluate the function first, as special forms see their arguments
+	// unevaluated
+	f := v.cells[0].lvalEval(e)
+	if f.ltype == lvalErrType {
+		return f
 	}
-	// If root (>) or S-expression, then create empty list
-	var x *lval
-	if mpc.GetTag(tree) == ">" {
-		x = lvalSexpr()
-	} else if strings.Contains(mpc.GetTag(tree), "sexpr") {
-		x = lvalSexpr()
-	} else if strings.Contains(mpc.GetTag(tree), "qexpr") {
-		x = lvalQexpr()
+	if f.ltype != lvalFunType {
+		return lvalErr("S-expression does not start with symbol! got: %s", f.ltypeName()).lvalAt(v.pos)
 	}
-	// Fill the cell list with any valid expressions in the children
-	for i := 0; i < mpc.GetNumChildren(tree); i++ {
-		iChild := mpc.GetChild(tree, i)
-		iContents := mpc.GetContents(iChild)
-		if iContents == "(" ||
-			iContents == ")" ||
-			iContents == "{" ||
-			iContents == "}" ||
-			mpc.GetTag(iChild) == "regex" {
-			continue
-		} else if strings.Contains(mpc.GetTag(iChild), "comment") {
-			continue
-		} else {
-			x = lvalAdd(x, lvalRead(iChild))
+	// The arguments are gathered in a list of their own, which the function
+	// is free to take apart
+	a := lvalSexpr()
+	a.pos = v.pos
+	a.cells = make([]*lval, 0, v.cellCount()-1)
+	if f.special || f.macro {
+		a.cells = append(a.cells, v.cells[1:]...)
+	} else {
+		// Evaluate children
+		for _, cell := range v.cells[1:] {
+			a.cells = append(a.cells, cell.lvalEval(e))
+		}
+		// Error checking
+		for i, cell := range a.cells {
+			if cell.ltype == lvalErrType {
+				return a.lvalTake(i)
+			}
 		}
-		strconv.ParseInt(mpc.GetContents(tree), 10, 0)
+	}
+	// Use first element as a function to get result
+	x := lvalCall(e, f, a).lvalAt(v.pos)
+	if f.macro {
+		x = lvalExpand(e, x)
+	}
+	if x.ltype == lvalErrType || x.ltype == lvalThunkType {
+		x.trace = append(x.trace, lframe{name, v.pos})
 	}
 	return x
 }
 
-func (v *lval) lvalEvalSexpr(e *lenv) *lval {
-	// Evaluate children
-	for i, cell := range v.cells {
-		v.cells[i] = cell.lvalEval(e)
+// lvalExpand finishes the call of a macro, whose result is the code to be
+// evaluated in the environment the macro was called from
+func lvalExpand(e *lenv, x *lval) *lval {
+	if x.ltype == lvalThunkType {
+		x = x.body.lvalEval(x.env)
 	}
-	// Error checking
-	for i, cell := range v.cells {
-		if cell.ltype == lvalErrType {
-			return v.lvalTake(i)
-		}
-	}
-	// Empty Expression
-	if v.cellCount() == 0 {
-		return v
-	}
-	// Single Expression
-	if v.cellCount() == 1 {
-		return v.lvalTake(0).lvalEval(e)
-	}
-	// Ensure first element is a symbol
-	f := v.lvalPop(0)
-	if f.ltype != lvalFunType {
-		return lvalErr("S-expression does not start with symbol! got: %s", f.ltypeName())
+	switch x.ltype {
+	case lvalErrType:
+		return x
+	case lvalQexprType:
+		x = lvalSexprOf(x)
 	}
-	// Use first element as a function to get result
-	return lvalCall(e, f, v)
+	return lvalThunk(x, e)
 }
 
+// Number of tail calls remembered by lvalEval for stack traces
+const maxTailFrames = 16
+
 func (v *lval) lvalEval(e *lenv) *lval {
-	if v.ltype == lvalSymType {
-		return e.lenvGet(v)
-	}
+	st := e.lenvState()
 	if v.ltype == lvalSexprType {
-		return v.lvalEvalSexpr(e)
+		if y := st.lstateEnter(); y != nil {
+			st.lstateLeave()
+			return y.lvalAt(v.pos)
+		}
+		defer st.lstateLeave()
+	}
+	// Tail calls made so far, oldest first
+	var calls []lframe
+	omitted := 0
+	for {
+		var x *lval
+		if y := st.lstateStep(); y != nil {
+			return y.lvalAt(v.pos)
+		}
+		switch v.ltype {
+		case lvalSymType:
+			x = e.lenvGet(v).lvalAt(v.pos)
+		case lvalSexprType:
+			x = v.lvalEvalSexpr(e)
+		case lvalMapType:
+			x = v.lvalEvalMap(e)
+		default:
+			x = v
+		}
+		if x.ltype != lvalThunkType {
+			if x.ltype == lvalErrType {
+				for i := len(calls) - 1; i >= 0; i-- {
+					x.trace = append(x.trace, calls[i])
+				}
+				if omitted > 0 {
+					x.trace = append(x.trace, lframe{fmt.Sprintf("<%d tail calls>", omitted), nil})
+				}
+			}
+			return x
+		}
+		// Loop on the expression in tail position instead of recursing
+		calls = append(calls, x.trace...)
+		if len(calls) > maxTailFrames {
+			omitted += len(calls) - maxTailFrames
+			calls = append(calls[:0], calls[len(calls)-maxTailFrames:]...)
+		}
+		v, e = x.body, x.env
+	}
+}
+
+// lvalAt records p as the position of an error that has none yet, so the
+// innermost expression that failed is the one reported
+func (v *lval) lvalAt(p *lpos) *lval {
+	if v.ltype == lvalErrType && v.pos == nil {
+		v.pos = p
 	}
 	return v
 }
@@ -334,81 +595,100 @@ func (v *lval) lvalTake(i int) *lval {
 	return v.lvalPop(i)
 }
 
-func lvalJoin(x *lval, y *lval) *lval {
-	for y.cellCount() > 0 {
-		x = lvalAdd(x, y.lvalPop(0))
-	}
-	return x
-}
-
 func lvalCall(e *lenv, f *lval, a *lval) *lval {
 	// Simple Builtin case:
 	if f.builtin != nil {
 		return f.builtin(e, a)
 	}
+	// Bind arguments in a new environment within the function's, so that
+	// each call has its own
+	env := lenvNew()
+	env.par = f.env
+	formals := f.formals.cells
 	// Record argument counts
 	given := a.cellCount()
-	total := f.formals.cellCount()
+	total := len(formals)
 	// While arguments still remain to be processed
 	for a.cellCount() > 0 {
 		// If we've ran out of formal arguments to bind
-		if f.formals.cellCount() == 0 {
+		if len(formals) == 0 {
 			return lvalErr("Function passed too many arguments. Got %d, Expected %d", given, total)
 		}
-		// Pop the first symbol from the formal
-		sym := f.formals.lvalPop(0)
+		// Take the first symbol from the formals
+		sym := formals[0]
+		formals = formals[1:]
 		// Special case to deal with '&'
 		if sym.sym == "&" {
 			// Ensure '&' is followed by another symbol
-			if f.formals.cellCount() != 1 {
+			if len(formals) != 1 {
 				return lvalErr("Function format invalid. Symbol '&' was not followed by 1 symbol.)")
 			}
 			// Next formal should be bound to the remaining arguments
-			nsym := f.formals.lvalPop(0)
-			f.env.lenvPut(nsym, builtinList(e, a))
+			rest := builtinList(e, a)
+			if rest.ltype == lvalErrType {
+				return rest
+			}
+			env.lenvPut(formals[0], rest)
+			formals = formals[1:]
 			break
 		}
-		// Pop the next argument from the list
-		val := a.lvalPop(0)
-		// Bind a copy into the function's environment
-		f.env.lenvPut(sym, val)
+		// Pop the next argument from the list, and bind it
+		env.lenvPut(sym, a.lvalPop(0))
 	}
 	// If '&' remains in the formal list, bind to an empty list
-	if f.formals.cellCount() > 0 && f.formals.cells[0].sym == "&" {
+	if len(formals) > 0 && formals[0].sym == "&" {
 		// Check to ensure that '&' is not passed in invalidly
-		if f.formals.cellCount() != 2 {
+		if len(formals) != 2 {
 			return lvalErr("Function forma invalid. Symbol '&' not followed by single symbol")
 		}
-		// Pop '&' symbol
-		f.formals.lvalPop(0)
-		// Pop the next symbol and create an empty list
-		sym := f.formals.lvalPop(0)
-		val := lvalQexpr()
-		// Bind to the environment
-		f.env.lenvPut(sym, val)
-	}
-	// If all formals have been bound, evaluate
-	if f.formals.cellCount() == 0 {
-		// Set environment parent to evaluation environment
-		f.env.par = e
-		// Evaluate and return
-		return builtinEval(f.env, lvalAdd(lvalSexpr(), lvalCopy(f.body)))
+		env.lenvPut(formals[1], lvalQexpr())
+		formals = formals[2:]
+	}
+	// If all formals have been bound, evaluate the body in tail position
+	if len(formals) == 0 {
+		return lvalThunk(lvalSexprOf(f.body), env)
 	}
 	// Otherwise, return partially evaluated function
-	return lvalCopy(f)
+	g := lvalLambda(env, lvalSlice(lvalQexprType, formals), f.body)
+	g.macro = f.macro
+	return g
+}
+
+// lvalTruthy decides which branch a condition takes: #f, zero and the
+// empty list are false, and every other value is true
+func lvalTruthy(v *lval) bool {
+	switch v.ltype {
+	case lvalBoolType:
+		return v.boolean
+	case lvalNumType, lvalRatType, lvalFloatType:
+		return lvalNumCmp(v, lvalNum(0)) != 0
+	case lvalQexprType, lvalSexprType:
+		return v.cellCount() > 0
+	}
+	return true
 }
 
 func lvalEq(x, y *lval) bool {
+	// Numbers compare by value, whatever their type
+	if x.isNumber() && y.isNumber() {
+		if x.numRank() == numRankFloat || y.numRank() == numRankFloat {
+			return x.toFloat() == y.toFloat()
+		}
+		return lvalNumCmp(x, y) == 0
+	}
 	// Different types are never equal
 	if x.ltype != y.ltype {
 		return false
 	}
 	// Compare based on type
 	switch x.ltype {
-	case lvalNumType:
-		return x.num == y.num
-	case lvalErrType:
-		return x.err == y.err
+	case lvalBoolType:
+		return x.boolean == y.boolean
+	case lvalErrType, lvalCondType:
+		if x.thrown != nil && y.thrown != nil {
+			return lvalEq(x.thrown, y.thrown)
+		}
+		return x.err == y.err && x.thrown == nil && y.thrown == nil
 	case lvalSymType:
 		return x.sym == y.sym
 	case lvalStrType:
@@ -417,21 +697,41 @@ func lvalEq(x, y *lval) bool {
 		if x.builtin != nil || y.builtin != nil {
 			return &x.builtin == &y.builtin
 		}
-		return lvalEq(x.formals, y.formals) && lvalEq(x.body, y.body)
+		return x.macro == y.macro && lvalEq(x.formals, y.formals) && lvalEq(x.body, y.body)
 	case lvalQexprType:
 		fallthrough
 	case lvalSexprType:
-		// Compare each element in the lists
-		if x.cellCount() != y.cellCount() {
+		return lvalEqCells(x, y)
+	case lvalMapType:
+		// Map literals compare by their forms, as lists do
+		if x.entries == nil || y.entries == nil {
+			return x.entries == nil && y.entries == nil && lvalEqCells(x, y)
+		}
+		// Compare the value stored under each key
+		if len(x.entries) != len(y.entries) {
 			return false
 		}
-		for i := 0; i < x.cellCount(); i++ {
-			if !lvalEq(x.cells[i], y.cells[i]) {
+		for k, entry := range x.entries {
+			other, ok := y.entries[k]
+			if !ok || !lvalEq(entry.val, other.val) {
 				return false
 			}
 		}
-		// Lists have same elements
 		return true
 	}
 	return false
 }
+
+// lvalEqCells compares the cells of two lists, element by element
+func lvalEqCells(x, y *lval) bool {
+	if x.cellCount() != y.cellCount() {
+		return false
+	}
+	for i := 0; i < x.cellCount(); i++ {
+		if !lvalEq(x.cells[i], y.cells[i]) {
+			return false
+		}
+	}
+	// Lists have same elements
+	return true
+}
@@ -0,0 +1,181 @@
+package lispy
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// lentry is a key and value pair held in an lvalMapType
+type lentry struct {
+	key *lval
+	val *lval
+}
+
+// lvalMap creates an empty lval map
+func lvalMap() *lval {
+	v := new(lval)
+	v.ltype = lvalMapType
+	v.entries = make(map[string]lentry)
+	return v
+}
+
+// lvalMapKey returns the string a value is stored under in a map, or false
+// if the value cannot be used as a key. Numbers are keyed on their exact
+// value, so 1 and 1.0 share a key, but the float 0.1 and the rational 1/10
+// do not, even though == rounds 1/10 to a float and finds them equal. Every
+// NaN shares one key, so a NaN stored in a map can be found again. Other
+// values share a key when they are lvalEq.
+func lvalMapKey(k *lval) (string, bool) {
+	switch k.ltype {
+	case lvalFunType, lvalErrType, lvalCondType, lvalThunkType:
+		return "", false
+	case lvalNumType, lvalRatType:
+		return "Number:" + k.toRat().RatString(), true
+	case lvalFloatType:
+		if math.IsInf(k.fnum, 0) || math.IsNaN(k.fnum) {
+			return "Number:" + formatFloat(k.fnum), true
+		}
+		return "Number:" + new(big.Rat).SetFloat64(k.fnum).RatString(), true
+	case lvalSexprType, lvalQexprType:
+		// Lists are keyed on the keys of their elements, so that lists that
+		// are lvalEq, such as {1} and {1.0}, share a key
+		return lvalCellsKey(k.ltypeName(), k.cells)
+	case lvalMapType:
+		if k.entries == nil {
+			// Map literals are keyed on their forms, as lists are
+			return lvalCellsKey("Map literal", k.cells)
+		}
+		keys := make([]string, 0, 2*len(k.entries))
+		for _, entry := range k.lvalMapEntries() {
+			s, _ := lvalMapKey(entry.key)
+			v, ok := lvalMapKey(entry.val)
+			if !ok {
+				return "", false
+			}
+			keys = append(keys, s, v)
+		}
+		return k.ltypeName() + ":(" + strings.Join(keys, " ") + ")", true
+	}
+	return k.ltypeName() + ":" + k.lvalString(), true
+}
+
+// lvalMapLiteral creates a map literal, whose key and value forms are added
+// to its cells
+func lvalMapLiteral() *lval {
+	v := new(lval)
+	v.ltype = lvalMapType
+	return v
+}
+
+// lvalEvalMap evaluates the key and value forms of a map literal left to
+// right into a new map, as hash-map does with its arguments. A map that has
+// been built already evaluates to itself.
+func (v *lval) lvalEvalMap(e *lenv) *lval {
+	if v.entries != nil {
+		return v
+	}
+	if err := e.lenvState().lstateAlloc(v.cellCount(), 0); err != nil {
+		return err
+	}
+	m := lvalMap()
+	for i := 0; i+1 < v.cellCount(); i += 2 {
+		key := v.cells[i].lvalEval(e)
+		if key.ltype == lvalErrType {
+			return key
+		}
+		val := v.cells[i+1].lvalEval(e)
+		if val.ltype == lvalErrType {
+			return val
+		}
+		if x := m.lvalMapPut(key, val); x.ltype == lvalErrType {
+			return x.lvalAt(v.pos)
+		}
+	}
+	return m
+}
+
+// lvalCellsKey returns the map key of a list of cells, made of the keys of
+// its elements
+func lvalCellsKey(name string, cells []*lval) (string, bool) {
+	keys := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		s, ok := lvalMapKey(cell)
+		if !ok {
+			return "", false
+		}
+		keys = append(keys, s)
+	}
+	return name + ":(" + strings.Join(keys, " ") + ")", true
+}
+
+// lvalMapCopy creates a map with the entries of m, to be changed without
+// changing m
+func lvalMapCopy(m *lval) *lval {
+	v := lvalMap()
+	v.pos = m.pos
+	for k, entry := range m.entries {
+		v.entries[k] = entry
+	}
+	return v
+}
+
+// lvalMapPut stores val under key, reporting an error for keys
+// that cannot be stored
+func (v *lval) lvalMapPut(key, val *lval) *lval {
+	s, ok := lvalMapKey(key)
+	if !ok {
+		return lvalErr("Map keys cannot be of type %s", key.ltypeName())
+	}
+	v.entries[s] = lentry{key, val}
+	return v
+}
+
+// lvalMapGet returns the value stored under key, or nil if there is none
+func (v *lval) lvalMapGet(key *lval) *lval {
+	s, ok := lvalMapKey(key)
+	if !ok {
+		return nil
+	}
+	entry, ok := v.entries[s]
+	if !ok {
+		return nil
+	}
+	return entry.val
+}
+
+// lvalMapEntries lists the entries of a map ordered by key, so that maps
+// print and iterate the same way every time. Those of a map literal are its
+// forms, in the order they were read.
+func (v *lval) lvalMapEntries() []lentry {
+	if v.entries == nil {
+		// Map literals keep the order their forms were read in
+		entries := make([]lentry, 0, v.cellCount()/2)
+		for i := 0; i+1 < v.cellCount(); i += 2 {
+			entries = append(entries, lentry{v.cells[i], v.cells[i+1]})
+		}
+		return entries
+	}
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]lentry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, v.entries[name])
+	}
+	return entries
+}
+
+func (v *lval) lvalMapString() string {
+	s := "#{"
+	for i, entry := range v.lvalMapEntries() {
+		if i > 0 {
+			s += " "
+		}
+		s += entry.key.lvalString() + " " + entry.val.lvalString()
+	}
+	return s + "}"
+}
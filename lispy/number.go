@@ -0,0 +1,329 @@
+package lispy
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Ranks of the numeric types. Arithmetic on mixed operands is carried out
+// in the highest ranked type among them.
+const (
+	numRankInt = iota
+	numRankRat
+	numRankFloat
+)
+
+func (v *lval) isNumber() bool {
+	return v.ltype == lvalNumType || v.ltype == lvalRatType || v.ltype == lvalFloatType
+}
+
+func (v *lval) numRank() int {
+	switch v.ltype {
+	case lvalFloatType:
+		return numRankFloat
+	case lvalRatType:
+		return numRankRat
+	}
+	return numRankInt
+}
+
+// toFloat converts any numeric lval to a float64
+func (v *lval) toFloat() float64 {
+	if v.ltype == lvalFloatType {
+		return v.fnum
+	}
+	if v.ltype == lvalRatType {
+		f, _ := v.rat.Float64()
+		return f
+	}
+	if v.bnum != nil {
+		f, _ := new(big.Float).SetInt(v.bnum).Float64()
+		return f
+	}
+	return float64(v.num)
+}
+
+// toBig converts an integer lval to a big.Int. The result must not be
+// modified, as it may be shared with v.
+func (v *lval) toBig() *big.Int {
+	if v.bnum != nil {
+		return v.bnum
+	}
+	return big.NewInt(v.num)
+}
+
+// toRat converts an integer or rational lval to a big.Rat. The result must
+// not be modified, as it may be shared with v.
+func (v *lval) toRat() *big.Rat {
+	if v.ltype == lvalRatType {
+		return v.rat
+	}
+	return new(big.Rat).SetInt(v.toBig())
+}
+
+// maxPowBits caps the bits of powers even without Limits, so that one like
+// (^ 2 (^ 2 40)) fails rather than exhausting memory
+const maxPowBits = 1 << 30
+
+// lvalNumBits returns the bits of an integer, or the larger of those of the
+// numerator and denominator of a rational
+func lvalNumBits(x *lval) int {
+	switch {
+	case x.ltype == lvalRatType:
+		if n, d := x.rat.Num().BitLen(), x.rat.Denom().BitLen(); n > d {
+			return n
+		} else {
+			return d
+		}
+	case x.ltype == lvalFloatType:
+		return 0
+	case x.bnum != nil:
+		return x.bnum.BitLen()
+	case x.num < 0:
+		return bits.Len64(-uint64(x.num))
+	}
+	return bits.Len64(uint64(x.num))
+}
+
+// powBits returns the fewest bits a base of n bits raised to the power e can
+// have, saturating rather than overflowing. A base of n bits is at least
+// 2^(n-1), so its power has at least e*(n-1)+1 bits.
+func powBits(n int, e *big.Int) uint64 {
+	// Powers of 0, 1 and -1 do not grow
+	if n <= 1 {
+		return uint64(n)
+	}
+	e = new(big.Int).Abs(e)
+	if !e.IsUint64() || e.Uint64() > (math.MaxUint64-1)/uint64(n-1) {
+		return math.MaxUint64
+	}
+	return e.Uint64()*uint64(n-1) + 1
+}
+
+// lvalPowBits returns the fewest bits x raised to the power y can have,
+// which is 0 for powers worked out in floating point
+func lvalPowBits(x, y *lval) uint64 {
+	if x.ltype == lvalFloatType || y.ltype != lvalNumType {
+		return 0
+	}
+	return powBits(lvalNumBits(x), y.toBig())
+}
+
+// formatFloat prints a float so that it reads back as a float
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
+// lvalNeg negates a numeric lval
+func lvalNeg(x *lval) *lval {
+	if x.ltype == lvalFloatType {
+		return lvalFloat(-x.fnum)
+	}
+	if x.ltype == lvalRatType {
+		return lvalRat(new(big.Rat).Neg(x.rat))
+	}
+	if x.bnum != nil || x.num == math.MinInt64 {
+		return lvalBigNum(new(big.Int).Neg(x.toBig()))
+	}
+	return lvalNum(-x.num)
+}
+
+// lvalArith applies the operator op to two numeric lvals
+func lvalArith(op string, x, y *lval) *lval {
+	switch maxRank(x, y) {
+	case numRankFloat:
+		return lvalArithFloat(op, x.toFloat(), y.toFloat())
+	case numRankRat:
+		return lvalArithRat(op, x, y)
+	}
+	return lvalArithInt(op, x, y)
+}
+
+func maxRank(x, y *lval) int {
+	if x.numRank() > y.numRank() {
+		return x.numRank()
+	}
+	return y.numRank()
+}
+
+func lvalArithInt(op string, x, y *lval) *lval {
+	if (op == "/" || op == "%") && y.bnum == nil && y.num == 0 {
+		if op == "/" {
+			return lvalErr("Division By Zero!")
+		}
+		return lvalErr("Modulus By Zero!")
+	}
+	if x.bnum == nil && y.bnum == nil {
+		if r, ok := arithInt64(op, x.num, y.num); ok {
+			return lvalNum(r)
+		}
+	}
+	// Fall back to arbitrary precision when either operand is big or
+	// the result overflows an int64
+	return lvalArithBig(op, x.toBig(), y.toBig())
+}
+
+// arithInt64 applies op to two int64s, reporting false if the result
+// overflows or has to be worked out with big integers
+func arithInt64(op string, x, y int64) (int64, bool) {
+	switch op {
+	case "+":
+		r := x + y
+		return r, (x >= 0) != (y >= 0) || (r >= 0) == (x >= 0)
+	case "-":
+		r := x - y
+		return r, (x >= 0) == (y >= 0) || (r >= 0) == (x >= 0)
+	case "*":
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		r := x * y
+		if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return 0, false
+		}
+		return r, true
+	case "/":
+		// Inexact division gives a rational
+		return x / y, x%y == 0 && !(x == math.MinInt64 && y == -1)
+	case "%":
+		if y == -1 {
+			return 0, true
+		}
+		return x % y, true
+	}
+	return 0, false
+}
+
+func lvalArithBig(op string, x, y *big.Int) *lval {
+	r := new(big.Int)
+	switch op {
+	case "+":
+		r.Add(x, y)
+	case "-":
+		r.Sub(x, y)
+	case "*":
+		r.Mul(x, y)
+	case "^":
+		// Negative powers of integers are fractions
+		if y.Sign() < 0 {
+			return lvalArithRat(op, lvalBigNum(x), lvalBigNum(y))
+		}
+		if powBits(x.BitLen(), y) > maxPowBits {
+			return lvalErr("Power too large: result would have over %d bits", maxPowBits)
+		}
+		r.Exp(x, y, nil)
+	case "/":
+		if new(big.Int).Rem(x, y).Sign() != 0 {
+			return lvalRat(new(big.Rat).SetFrac(x, y))
+		}
+		r.Quo(x, y)
+	case "%":
+		r.Rem(x, y)
+	default:
+		return lvalErr("Unknown operator: %s", op)
+	}
+	return lvalBigNum(r)
+}
+
+func lvalArithRat(op string, x, y *lval) *lval {
+	a, b := x.toRat(), y.toRat()
+	r := new(big.Rat)
+	switch op {
+	case "+":
+		r.Add(a, b)
+	case "-":
+		r.Sub(a, b)
+	case "*":
+		r.Mul(a, b)
+	case "^":
+		// Only whole powers keep a rational exact
+		if y.ltype != lvalNumType {
+			return lvalArithFloat(op, x.toFloat(), y.toFloat())
+		}
+		if lvalPowBits(x, y) > maxPowBits {
+			return lvalErr("Power too large: result would have over %d bits", maxPowBits)
+		}
+		e := new(big.Int).Abs(y.toBig())
+		num := new(big.Int).Exp(a.Num(), e, nil)
+		den := new(big.Int).Exp(a.Denom(), e, nil)
+		if y.toBig().Sign() < 0 {
+			num, den = den, num
+		}
+		if den.Sign() == 0 {
+			return lvalErr("Division By Zero!")
+		}
+		r.SetFrac(num, den)
+	case "/":
+		if b.Sign() == 0 {
+			return lvalErr("Division By Zero!")
+		}
+		r.Quo(a, b)
+	case "%":
+		if b.Sign() == 0 {
+			return lvalErr("Modulus By Zero!")
+		}
+		// Remainder of the quotient truncated towards zero, as for integers
+		q := new(big.Rat).Quo(a, b)
+		t := new(big.Int).Quo(q.Num(), q.Denom())
+		r.Sub(a, new(big.Rat).Mul(b, new(big.Rat).SetInt(t)))
+	default:
+		return lvalErr("Unknown operator: %s", op)
+	}
+	return lvalRat(r)
+}
+
+func lvalArithFloat(op string, x, y float64) *lval {
+	switch op {
+	case "+":
+		return lvalFloat(x + y)
+	case "-":
+		return lvalFloat(x - y)
+	case "*":
+		return lvalFloat(x * y)
+	case "^":
+		return lvalFloat(math.Pow(x, y))
+	case "/":
+		if y == 0 {
+			return lvalErr("Division By Zero!")
+		}
+		return lvalFloat(x / y)
+	case "%":
+		if y == 0 {
+			return lvalErr("Modulus By Zero!")
+		}
+		return lvalFloat(math.Mod(x, y))
+	}
+	return lvalErr("Unknown operator: %s", op)
+}
+
+// lvalNumCmp compares two numeric lvals, returning -1, 0 or 1
+func lvalNumCmp(x, y *lval) int {
+	switch maxRank(x, y) {
+	case numRankFloat:
+		a, b := x.toFloat(), y.toFloat()
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case numRankRat:
+		return x.toRat().Cmp(y.toRat())
+	}
+	if x.bnum != nil || y.bnum != nil {
+		return x.toBig().Cmp(y.toBig())
+	}
+	if x.num < y.num {
+		return -1
+	} else if x.num > y.num {
+		return 1
+	}
+	return 0
+}
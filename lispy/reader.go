@@ -0,0 +1,462 @@
+package lispy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Token kinds produced by the lexer
+const (
+	tokNumber = iota
+	tokBool
+	tokSymbol
+	tokString
+	tokComment
+	tokOpen  // '(', '{' or '#{'
+	tokClose // ')' or '}'
+	tokQuote // '`', ',' or ",@"
+	tokEOF
+)
+
+type token struct {
+	kind int
+	text string
+	line int
+	col  int
+}
+
+// lexer splits Lispy source into tokens, following the grammar:
+//
+//	number  : /-?[0-9]+(\/[0-9]+|(\.[0-9]+)?([eE][-+]?[0-9]+)?)/
+//	symbol  : /[a-zA-Z0-9_+\-*%^\/\\=<>!&?]+/
+//	boolean : "#t" | "#f"
+//	string  : /"(\\.|[^"])*"/
+//	comment : /;[^\r\n]*/ | /^#![^\r\n]*/
+//	sexpr   : '(' <expr>* ')'
+//	qexpr   : '{' <expr>* '}'
+//	map     : "#{" (<expr> <expr>)* '}'
+//	quote   : ('`' | ",@" | ',') <expr>
+type lexer struct {
+	file string
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func newLexer(file, src string) *lexer {
+	return &lexer{file: file, src: src, line: 1, col: 1}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSymbolChar(c byte) bool {
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) {
+		return true
+	}
+	return strings.IndexByte("_+-*%^/\\=<>!&?", c) >= 0
+}
+
+func (x *lexer) peek(offset int) byte {
+	if x.pos+offset >= len(x.src) {
+		return 0
+	}
+	return x.src[x.pos+offset]
+}
+
+// advance moves past n bytes, keeping the line and column up to date
+func (x *lexer) advance(n int) {
+	for i := 0; i < n && x.pos < len(x.src); i++ {
+		if x.src[x.pos] == '\n' {
+			x.line++
+			x.col = 1
+		} else {
+			x.col++
+		}
+		x.pos++
+	}
+}
+
+func (x *lexer) advanceDigits() {
+	for x.pos < len(x.src) && isDigit(x.src[x.pos]) {
+		x.advance(1)
+	}
+}
+
+func (x *lexer) errorf(line, col int, f string, a ...interface{}) error {
+	return &ParseError{File: x.file, Line: line, Col: col, Msg: fmt.Sprintf(f, a...)}
+}
+
+// next returns the next token in the input
+func (x *lexer) next() (token, error) {
+	for x.pos < len(x.src) && isSpace(x.src[x.pos]) {
+		x.advance(1)
+	}
+	t := token{line: x.line, col: x.col}
+	if x.pos >= len(x.src) {
+		t.kind = tokEOF
+		return t, nil
+	}
+	start := x.pos
+	c := x.src[x.pos]
+	switch {
+	case c == '(' || c == '{':
+		t.kind = tokOpen
+		x.advance(1)
+	case c == ')' || c == '}':
+		t.kind = tokClose
+		x.advance(1)
+	case c == '`' || c == ',':
+		t.kind = tokQuote
+		if c == ',' && x.peek(1) == '@' {
+			x.advance(1)
+		}
+		x.advance(1)
+	case c == ';' || (start == 0 && c == '#' && x.peek(1) == '!'):
+		// A #! line starting a script is a comment, for the shell to read
+		t.kind = tokComment
+		for x.pos < len(x.src) && x.src[x.pos] != '\r' && x.src[x.pos] != '\n' {
+			x.advance(1)
+		}
+	case c == '#' && x.peek(1) == '{':
+		t.kind = tokOpen
+		x.advance(2)
+	case c == '#' && (x.peek(1) == 't' || x.peek(1) == 'f') && !isSymbolChar(x.peek(2)):
+		t.kind = tokBool
+		x.advance(2)
+	case c == '"':
+		t.kind = tokString
+		x.advance(1)
+		for {
+			if x.pos >= len(x.src) {
+				return t, x.errorf(t.line, t.col, "unterminated string")
+			}
+			if x.src[x.pos] == '\\' {
+				x.advance(2)
+				continue
+			}
+			if x.src[x.pos] == '"' {
+				x.advance(1)
+				break
+			}
+			x.advance(1)
+		}
+	case isDigit(c) || (c == '-' && isDigit(x.peek(1))):
+		t.kind = tokNumber
+		x.advance(1)
+		x.advanceDigits()
+		// Denominator of a rational
+		if x.peek(0) == '/' && isDigit(x.peek(1)) {
+			x.advance(1)
+			x.advanceDigits()
+			break
+		}
+		// Fractional part
+		if x.peek(0) == '.' && isDigit(x.peek(1)) {
+			x.advance(1)
+			x.advanceDigits()
+		}
+		// Exponent
+		if x.peek(0) == 'e' || x.peek(0) == 'E' {
+			if isDigit(x.peek(1)) {
+				x.advance(1)
+				x.advanceDigits()
+			} else if (x.peek(1) == '-' || x.peek(1) == '+') && isDigit(x.peek(2)) {
+				x.advance(2)
+				x.advanceDigits()
+			}
+		}
+	case isSymbolChar(c):
+		t.kind = tokSymbol
+		for x.pos < len(x.src) && isSymbolChar(x.src[x.pos]) {
+			x.advance(1)
+		}
+	default:
+		return t, x.errorf(t.line, t.col, "unexpected character '%c'", c)
+	}
+	t.text = x.src[start:x.pos]
+	return t, nil
+}
+
+// reader builds lval trees out of the tokens of a lexer
+type reader struct {
+	lex *lexer
+	tok token
+}
+
+func (r *reader) scan() error {
+	t, err := r.lex.next()
+	r.tok = t
+	return err
+}
+
+func (r *reader) posOf(t token) *lpos {
+	return &lpos{r.lex.file, t.line, t.col}
+}
+
+// lvalReadString parses a whole source text into an S-expression holding
+// every top level expression
+func lvalReadString(file, src string) (*lval, error) {
+	r := &reader{lex: newLexer(file, src)}
+	if err := r.scan(); err != nil {
+		return nil, err
+	}
+	x := lvalSexpr()
+	x.pos = &lpos{file, 1, 1}
+	for r.tok.kind != tokEOF {
+		if r.tok.kind == tokClose {
+			return nil, r.lex.errorf(r.tok.line, r.tok.col, "unexpected '%s'", r.tok.text)
+		}
+		v, err := r.readExpr()
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			x = lvalAdd(x, v)
+		}
+	}
+	return x, nil
+}
+
+// readExpr reads the expression starting at the current token. Comments
+// yield a nil lval.
+func (r *reader) readExpr() (*lval, error) {
+	t := r.tok
+	var x *lval
+	switch t.kind {
+	case tokNumber:
+		x = lvalReadNum(t.text)
+	case tokBool:
+		x = lvalBool(t.text == "#t")
+	case tokSymbol:
+		x = lvalSym(t.text)
+	case tokString:
+		x = lvalReadStr(t.text)
+	case tokComment:
+		x = nil
+	case tokOpen:
+		return r.readList(t)
+	case tokQuote:
+		return r.readQuote(t)
+	}
+	if x != nil {
+		x.pos = r.posOf(t)
+	}
+	return x, r.scan()
+}
+
+// Symbols the quote prefixes are read as
+var quoteSymbols = map[string]string{
+	"`":  "quasiquote",
+	",":  "unquote",
+	",@": "unquote-splicing",
+}
+
+// readQuote reads a prefixed expression such as `x into (quasiquote x)
+func (r *reader) readQuote(t token) (*lval, error) {
+	if err := r.scan(); err != nil {
+		return nil, err
+	}
+	// Skip comments between the prefix and its expression
+	var v *lval
+	for v == nil {
+		if r.tok.kind == tokEOF || r.tok.kind == tokClose {
+			return nil, r.lex.errorf(t.line, t.col, "expected an expression after '%s'", t.text)
+		}
+		var err error
+		if v, err = r.readExpr(); err != nil {
+			return nil, err
+		}
+	}
+	sym := lvalSym(quoteSymbols[t.text])
+	sym.pos = r.posOf(t)
+	x := lvalAdd(lvalAdd(lvalSexpr(), sym), v)
+	x.pos = sym.pos
+	return x, nil
+}
+
+// readList reads the cells of an S-expression or Q-expression up to the
+// matching closing bracket
+func (r *reader) readList(open token) (*lval, error) {
+	var x *lval
+	closer := ")"
+	if open.text == "{" || open.text == "#{" {
+		x = lvalQexpr()
+		closer = "}"
+	} else {
+		x = lvalSexpr()
+	}
+	x.pos = r.posOf(open)
+	if err := r.scan(); err != nil {
+		return nil, err
+	}
+	for r.tok.kind != tokClose {
+		if r.tok.kind == tokEOF {
+			return nil, r.lex.errorf(r.tok.line, r.tok.col, "expected '%s' to close '%s' at %d:%d",
+				closer, open.text, open.line, open.col)
+		}
+		v, err := r.readExpr()
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			x = lvalAdd(x, v)
+		}
+	}
+	if r.tok.text != closer {
+		return nil, r.lex.errorf(r.tok.line, r.tok.col, "expected '%s' to close '%s' at %d:%d, got '%s'",
+			closer, open.text, open.line, open.col, r.tok.text)
+	}
+	if open.text == "#{" {
+		m, err := r.readMap(open, x)
+		if err != nil {
+			return nil, err
+		}
+		x = m
+	}
+	return x, r.scan()
+}
+
+// readMap pairs up the cells read for a map literal. They are kept in the
+// order they were read, and evaluated when the map is, as the arguments of
+// hash-map are.
+func (r *reader) readMap(open token, x *lval) (*lval, error) {
+	if x.cellCount()%2 != 0 {
+		return nil, r.lex.errorf(open.line, open.col, "map literal needs an even number of forms, got %d",
+			x.cellCount())
+	}
+	m := lvalMapLiteral()
+	m.pos = x.pos
+	m.cells = x.cells
+	return m, nil
+}
+
+func lvalReadNum(s string) *lval {
+	if strings.Contains(s, "/") {
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return lvalErr("Invalid Number: %s", s)
+		}
+		return lvalRat(r)
+	}
+	if strings.ContainsAny(s, ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return lvalErr("Invalid Number: %s", s)
+		}
+		return lvalFloat(f)
+	}
+	x, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		// Literals too large for an int64 are read as big integers
+		b, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return lvalErr("Invalid Number: %s", s)
+		}
+		return lvalBigNum(b)
+	}
+	return lvalNum(x)
+}
+
+func lvalReadStr(s string) *lval {
+	// Cut off quote characters
+	return lvalStr(unescape(s[1 : len(s)-1]))
+}
+
+// Escape sequences understood in string literals
+var (
+	escapeInput  = []byte{'\a', '\b', '\f', '\n', '\r', '\t', '\v', '\\', '\'', '"', 0}
+	escapeOutput = []string{`\a`, `\b`, `\f`, `\n`, `\r`, `\t`, `\v`, `\\`, `\'`, `\"`, `\0`}
+)
+
+// escape inserts escape characters into an input string
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if j := bytes.IndexByte(escapeInput, s[i]); j >= 0 {
+			b.WriteString(escapeOutput[j])
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// unescape converts escape sequences into the characters they encode
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			found := false
+			for j, out := range escapeOutput {
+				if out[1] == s[i+1] {
+					b.WriteByte(escapeInput[j])
+					found = true
+					break
+				}
+			}
+			if found {
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// printAst writes the token structure of the input, one node per line
+func printAst(w io.Writer, file, src string) error {
+	x := newLexer(file, src)
+	depth := 1
+	fmt.Fprintln(w, "> ")
+	for {
+		t, err := x.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokEOF {
+			return nil
+		}
+		if t.kind == tokClose {
+			depth--
+		}
+		indent := strings.Repeat("  ", depth)
+		switch t.kind {
+		case tokNumber:
+			fmt.Fprintf(w, "%snumber:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		case tokBool:
+			fmt.Fprintf(w, "%sboolean:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		case tokSymbol:
+			fmt.Fprintf(w, "%ssymbol:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		case tokString:
+			fmt.Fprintf(w, "%sstring:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		case tokQuote:
+			fmt.Fprintf(w, "%s%s:%d:%d '%s'\n", indent, quoteSymbols[t.text], t.line, t.col, t.text)
+		case tokComment:
+			fmt.Fprintf(w, "%scomment:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		case tokOpen:
+			tag := "sexpr"
+			if t.text == "{" {
+				tag = "qexpr"
+			} else if t.text == "#{" {
+				tag = "map"
+			}
+			fmt.Fprintf(w, "%s%s \n", indent, tag)
+			depth++
+			fmt.Fprintf(w, "%schar:%d:%d '%s'\n", strings.Repeat("  ", depth), t.line, t.col, t.text)
+		case tokClose:
+			fmt.Fprintf(w, "%s  char:%d:%d '%s'\n", indent, t.line, t.col, t.text)
+		}
+	}
+}
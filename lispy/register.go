@@ -0,0 +1,287 @@
+package lispy
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var (
+	valueType  = reflect.TypeOf(Value{})
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+	bigRatType = reflect.TypeOf((*big.Rat)(nil))
+)
+
+// RegisterFunc makes the Go function fn callable from Lispy as name.
+//
+// Arguments are converted to the parameter types of fn, which may be
+// integers, floats, bools, strings, *big.Int, *big.Rat, Value, slices and
+// maps of those, or interface{}, which receives the argument as a Value.
+// Results are converted back the same way. A final error result is not
+// returned to Lispy; when it is not nil, the call fails with its message.
+func (l *Lispy) RegisterFunc(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if !f.IsValid() {
+		return fmt.Errorf("cannot register %s: nil is not a function", name)
+	}
+	t := f.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("cannot register %s: %s is not a function", name, t)
+	}
+	if f.IsNil() {
+		return fmt.Errorf("cannot register %s: %s is nil", name, t)
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !isConvertible(in) {
+			return fmt.Errorf("cannot register %s: unsupported parameter type %s", name, in)
+		}
+	}
+	results := t.NumOut()
+	if results > 0 && t.Out(results-1) == errorType {
+		results--
+	}
+	if results > 1 {
+		return fmt.Errorf("cannot register %s: %s returns more than one value", name, t)
+	}
+	if results == 1 && !isConvertible(t.Out(0)) {
+		return fmt.Errorf("cannot register %s: unsupported result type %s", name, t.Out(0))
+	}
+	l.env.lenvAddBuiltin(name, func(e *lenv, a *lval) *lval {
+		x := callGo(name, f, a)
+		// Everything converted from Go is newly built
+		if err := e.lenvState().lstateAlloc(lvalSize(x)); err != nil {
+			return err
+		}
+		return x
+	})
+	return nil
+}
+
+// isConvertible reports whether values of type t can be passed between Go
+// and Lispy
+func isConvertible(t reflect.Type) bool {
+	switch t {
+	case valueType, bigIntType, bigRatType:
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	case reflect.Slice, reflect.Array:
+		return isConvertible(t.Elem())
+	case reflect.Map:
+		return isConvertible(t.Key()) && isConvertible(t.Elem())
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return false
+}
+
+// callGo calls the registered function f with the arguments in a
+func callGo(name string, f reflect.Value, a *lval) *lval {
+	t := f.Type()
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+	}
+	if a.cellCount() < fixed {
+		return lvalErr("Function '%s' passed too few arguments: %s", name, a.lvalString())
+	}
+	if !t.IsVariadic() && a.cellCount() > fixed {
+		return lvalErr("Function '%s' passed too many arguments: %s", name, a.lvalString())
+	}
+	args := make([]reflect.Value, a.cellCount())
+	for i, cell := range a.cells {
+		var in reflect.Type
+		if i < fixed {
+			in = t.In(i)
+		} else {
+			in = t.In(fixed).Elem()
+		}
+		arg, err := lvalToGo(cell, in)
+		if err != nil {
+			return lvalErr("Function '%s' passed incorrect type for argument %d: %s", name, i+1, err)
+		}
+		args[i] = arg
+	}
+	results := f.Call(args)
+	if n := len(results); n > 0 && t.Out(n-1) == errorType {
+		if err := results[n-1]; !err.IsNil() {
+			return lvalErr("%s", err.Interface().(error).Error())
+		}
+		results = results[:n-1]
+	}
+	if len(results) == 0 {
+		return lvalSexpr()
+	}
+	x, err := lvalFromGo(results[0])
+	if err != nil {
+		return lvalErr("Function '%s' returned %s", name, err)
+	}
+	return x
+}
+
+// lvalToGo converts x to a Go value of type t
+func lvalToGo(x *lval, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	wrong := fmt.Errorf("expected %s, got %s", t, x.ltypeName())
+	switch t {
+	case valueType:
+		v.Set(reflect.ValueOf(Value{lvalCopy(x)}))
+		return v, nil
+	case bigIntType:
+		if x.ltype != lvalNumType {
+			return v, wrong
+		}
+		v.Set(reflect.ValueOf(new(big.Int).Set(x.toBig())))
+		return v, nil
+	case bigRatType:
+		if x.ltype != lvalNumType && x.ltype != lvalRatType {
+			return v, wrong
+		}
+		v.Set(reflect.ValueOf(new(big.Rat).Set(x.toRat())))
+		return v, nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x.ltype != lvalNumType {
+			return v, wrong
+		}
+		if x.bnum != nil || v.OverflowInt(x.num) {
+			return v, fmt.Errorf("%s is out of range for %s", x.lvalString(), t)
+		}
+		v.SetInt(x.num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if x.ltype != lvalNumType {
+			return v, wrong
+		}
+		b := x.toBig()
+		if b.Sign() < 0 || !b.IsUint64() || v.OverflowUint(b.Uint64()) {
+			return v, fmt.Errorf("%s is out of range for %s", x.lvalString(), t)
+		}
+		v.SetUint(b.Uint64())
+	case reflect.Float32, reflect.Float64:
+		if !x.isNumber() {
+			return v, wrong
+		}
+		v.SetFloat(x.toFloat())
+	case reflect.Bool:
+		if x.ltype != lvalBoolType {
+			return v, wrong
+		}
+		v.SetBool(x.boolean)
+	case reflect.String:
+		if x.ltype != lvalStrType {
+			return v, wrong
+		}
+		v.SetString(x.str)
+	case reflect.Slice, reflect.Array:
+		if x.ltype != lvalQexprType {
+			return v, wrong
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, x.cellCount(), x.cellCount()))
+		} else if x.cellCount() != t.Len() {
+			return v, fmt.Errorf("expected %d elements for %s, got %d", t.Len(), t, x.cellCount())
+		}
+		for i, cell := range x.cells {
+			elem, err := lvalToGo(cell, t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		if x.ltype != lvalMapType || x.entries == nil {
+			return v, wrong
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(x.entries)))
+		for _, entry := range x.lvalMapEntries() {
+			key, err := lvalToGo(entry.key, t.Key())
+			if err != nil {
+				return v, err
+			}
+			val, err := lvalToGo(entry.val, t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.SetMapIndex(key, val)
+		}
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(Value{lvalCopy(x)}))
+	default:
+		return v, fmt.Errorf("unsupported type %s", t)
+	}
+	return v, nil
+}
+
+// lvalFromGo converts a Go value to an lval
+func lvalFromGo(v reflect.Value) (*lval, error) {
+	switch v.Type() {
+	case valueType:
+		return lvalCopy(v.Interface().(Value).lval()), nil
+	case bigIntType:
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil %s", v.Type())
+		}
+		return lvalBigNum(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	case bigRatType:
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil %s", v.Type())
+		}
+		return lvalRat(new(big.Rat).Set(v.Interface().(*big.Rat))), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lvalNum(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lvalBigNum(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return lvalFloat(v.Float()), nil
+	case reflect.Bool:
+		return lvalBool(v.Bool()), nil
+	case reflect.String:
+		return lvalStr(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		x := lvalQexpr()
+		for i := 0; i < v.Len(); i++ {
+			elem, err := lvalFromGo(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			x = lvalAdd(x, elem)
+		}
+		return x, nil
+	case reflect.Map:
+		m := lvalMap()
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := lvalFromGo(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			val, err := lvalFromGo(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			if y := m.lvalMapPut(key, val); y.ltype == lvalErrType {
+				return nil, fmt.Errorf("%s", y.err)
+			}
+		}
+		return m, nil
+	case reflect.Interface:
+		// Nothing returned in an interface is the empty expression
+		if v.IsNil() {
+			return lvalSexpr(), nil
+		}
+		return lvalFromGo(v.Elem())
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
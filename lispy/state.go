@@ -0,0 +1,211 @@
+package lispy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// lstate is what an interpreter shares between all of its environments
+type lstate struct {
+	stdout io.Writer
+	stderr io.Writer
+	stdin  *bufio.Reader
+	// Where load reads files from, or nil for the disk
+	fsys fs.FS
+
+	// Context of the evaluation in progress, and whether it has been seen
+	// to be cancelled
+	ctx       context.Context
+	cancelled bool
+
+	limits Limits
+	// Evaluations started from Go that are in progress
+	active int
+	// Use of the limits by the outermost evaluation
+	steps uint64
+	depth int
+	cells int
+	bytes int
+}
+
+func lstateNew() *lstate {
+	return &lstate{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		stdin:  bufio.NewReader(os.Stdin),
+		ctx:    context.Background(),
+	}
+}
+
+// Capabilities are what an interpreter may do outside of itself. Only the
+// builtins that a capability allows are installed, so the zero value gives
+// a sandbox that can compute but cannot touch files or the terminal.
+type Capabilities struct {
+	// Load allows load, which reads source files from the disk, or from FS
+	// when it is set
+	Load bool
+	FS   fs.FS
+	// Print allows print, which writes to the interpreter's stdout
+	Print bool
+	// Input allows read-line, which reads from the interpreter's stdin
+	Input bool
+}
+
+// AllCapabilities are the capabilities of an interpreter from InitLispy
+var AllCapabilities = Capabilities{Load: true, Print: true, Input: true}
+
+// lstateReadFile reads a source file from the filesystem of the interpreter
+func (st *lstate) lstateReadFile(name string) ([]byte, error) {
+	if st.fsys != nil {
+		// Not every fs.FS turns away paths that climb out of it
+		if !fs.ValidPath(name) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+		}
+		return fs.ReadFile(st.fsys, name)
+	}
+	return os.ReadFile(name)
+}
+
+// Limits caps the resources an evaluation started from Go may use, so that
+// untrusted code fails with an error rather than running forever or
+// exhausting memory. Zero fields are not limited.
+type Limits struct {
+	// Expressions evaluated
+	MaxSteps uint64
+	// Nested S-expressions being evaluated at once, which grows with
+	// recursion that is not in tail position
+	MaxDepth int
+	// Cells of the lists, and entries of the maps, newly built by builtins
+	MaxCells int
+	// Bytes of the strings newly built by builtins
+	MaxStringBytes int
+	// Bits of any one integer, or numerator or denominator of a rational,
+	// built by arithmetic
+	MaxNumberBits int
+}
+
+// Causes of the errors evaluation stops with when it is cancelled or runs
+// over its Limits
+var (
+	ErrCancelled   = errors.New("evaluation cancelled")
+	ErrSteps       = errors.New("step limit exceeded")
+	ErrDepth       = errors.New("call depth limit exceeded")
+	ErrCells       = errors.New("cell limit exceeded")
+	ErrStringBytes = errors.New("string limit exceeded")
+	ErrNumberBits  = errors.New("number limit exceeded")
+)
+
+// lvalStop creates an error that stops evaluation because of cause
+func lvalStop(cause error, f string, a ...interface{}) *lval {
+	x := lvalErr(f, a...)
+	x.cause = cause
+	return x
+}
+
+// lstateBegin starts an evaluation from Go, returning a function to call
+// when it is done. Evaluations nested within another share its budget.
+func (st *lstate) lstateBegin() func() {
+	if st.active == 0 {
+		st.steps, st.depth, st.cells, st.bytes = 0, 0, 0, 0
+	}
+	st.active++
+	return func() {
+		st.active--
+	}
+}
+
+// Number of steps between checks for cancellation, as checking the context
+// on every step would slow evaluation down
+const cancelCheckInterval = 256
+
+// lstateStep counts a step of evaluation, returning an error if evaluation
+// has to stop
+func (st *lstate) lstateStep() *lval {
+	if st == nil {
+		return nil
+	}
+	st.steps++
+	if st.steps%cancelCheckInterval == 0 && st.ctx.Err() != nil {
+		st.cancelled = true
+	}
+	// Once cancelled, every step fails so that evaluation unwinds quickly
+	if st.cancelled {
+		return lvalStop(ErrCancelled, "Evaluation cancelled")
+	}
+	if st.limits.MaxSteps > 0 && st.steps > st.limits.MaxSteps {
+		return lvalStop(ErrSteps, "Step limit of %d exceeded", st.limits.MaxSteps)
+	}
+	return nil
+}
+
+// lstateEnter counts the start of an S-expression's evaluation, which
+// lstateLeave counts the end of
+func (st *lstate) lstateEnter() *lval {
+	if st == nil {
+		return nil
+	}
+	st.depth++
+	if st.limits.MaxDepth > 0 && st.depth > st.limits.MaxDepth {
+		return lvalStop(ErrDepth, "Call depth limit of %d exceeded", st.limits.MaxDepth)
+	}
+	return nil
+}
+
+func (st *lstate) lstateLeave() {
+	if st != nil {
+		st.depth--
+	}
+}
+
+// lstateAlloc charges newly built cells and string bytes to the allocation
+// limits, returning an error if evaluation has to stop. Values that are only
+// passed along, such as the rest of a list returned by tail, are not
+// charged again.
+func (st *lstate) lstateAlloc(cells, bytes int) *lval {
+	if st == nil {
+		return nil
+	}
+	st.cells += cells
+	st.bytes += bytes
+	if st.limits.MaxCells > 0 && st.cells > st.limits.MaxCells {
+		return lvalStop(ErrCells, "Cell limit of %d exceeded", st.limits.MaxCells)
+	}
+	if st.limits.MaxStringBytes > 0 && st.bytes > st.limits.MaxStringBytes {
+		return lvalStop(ErrStringBytes, "String limit of %d bytes exceeded", st.limits.MaxStringBytes)
+	}
+	return nil
+}
+
+// lstateNumber stops evaluation when a number of the given bits is larger
+// than the limits allow
+func (st *lstate) lstateNumber(bits uint64) *lval {
+	if st == nil || st.limits.MaxNumberBits <= 0 || bits <= uint64(st.limits.MaxNumberBits) {
+		return nil
+	}
+	return lvalStop(ErrNumberBits, "Number limit of %d bits exceeded", st.limits.MaxNumberBits)
+}
+
+// lvalSize counts the cells and string bytes of x and everything within it
+func lvalSize(x *lval) (cells, bytes int) {
+	switch x.ltype {
+	case lvalStrType:
+		return 0, len(x.str)
+	case lvalSexprType, lvalQexprType:
+		cells = x.cellCount()
+		for _, cell := range x.cells {
+			c, b := lvalSize(cell)
+			cells, bytes = cells+c, bytes+b
+		}
+	case lvalMapType:
+		for _, entry := range x.entries {
+			kc, kb := lvalSize(entry.key)
+			vc, vb := lvalSize(entry.val)
+			cells, bytes = cells+2+kc+vc, bytes+kb+vb
+		}
+	}
+	return cells, bytes
+}
@@ -0,0 +1,240 @@
+package lispy
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Kind is the type of a Value
+type Kind int
+
+// Kinds of Value
+const (
+	NumberKind    Kind = lvalNumType
+	RationalKind  Kind = lvalRatType
+	FloatKind     Kind = lvalFloatType
+	BooleanKind   Kind = lvalBoolType
+	SymbolKind    Kind = lvalSymType
+	StringKind    Kind = lvalStrType
+	FunctionKind  Kind = lvalFunType
+	SExprKind     Kind = lvalSexprType
+	QExprKind     Kind = lvalQexprType
+	MapKind       Kind = lvalMapType
+	ErrorKind     Kind = lvalErrType
+	ConditionKind Kind = lvalCondType
+)
+
+func (k Kind) String() string {
+	return ltypeName(int(k))
+}
+
+// Value is a Lispy value passed between Lispy and a host program. Values
+// are copied on their way into the interpreter, so evaluation never changes
+// a Value that is held onto. The zero Value is the empty S-expression.
+type Value struct {
+	v *lval
+}
+
+// Entry is a key and value pair of a map Value
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+// lval returns the lval held by x, to be read but not modified
+func (x Value) lval() *lval {
+	if x.v == nil {
+		return lvalSexpr()
+	}
+	return x.v
+}
+
+// Kind returns the type of x
+func (x Value) Kind() Kind {
+	return Kind(x.lval().ltype)
+}
+
+// String returns x as the REPL would print it
+func (x Value) String() string {
+	return x.lval().lvalString()
+}
+
+// Int returns the value of an integer that fits in an int64
+func (x Value) Int() (int64, bool) {
+	v := x.lval()
+	if v.ltype != lvalNumType || v.bnum != nil {
+		return 0, false
+	}
+	return v.num, true
+}
+
+// BigInt returns the value of an integer of any size
+func (x Value) BigInt() (*big.Int, bool) {
+	v := x.lval()
+	if v.ltype != lvalNumType {
+		return nil, false
+	}
+	return new(big.Int).Set(v.toBig()), true
+}
+
+// Rat returns the value of an integer or rational number
+func (x Value) Rat() (*big.Rat, bool) {
+	v := x.lval()
+	if v.ltype != lvalNumType && v.ltype != lvalRatType {
+		return nil, false
+	}
+	return new(big.Rat).Set(v.toRat()), true
+}
+
+// Float returns the value of any number as a float64
+func (x Value) Float() (float64, bool) {
+	v := x.lval()
+	if !v.isNumber() {
+		return 0, false
+	}
+	return v.toFloat(), true
+}
+
+// Bool returns the value of a boolean
+func (x Value) Bool() (bool, bool) {
+	v := x.lval()
+	if v.ltype != lvalBoolType {
+		return false, false
+	}
+	return v.boolean, true
+}
+
+// Truthy reports whether if would take x as true
+func (x Value) Truthy() bool {
+	return lvalTruthy(x.lval())
+}
+
+// Text returns the contents of a string, without quotes or escapes
+func (x Value) Text() (string, bool) {
+	v := x.lval()
+	if v.ltype != lvalStrType {
+		return "", false
+	}
+	return v.str, true
+}
+
+// Symbol returns the name of a symbol
+func (x Value) Symbol() (string, bool) {
+	v := x.lval()
+	if v.ltype != lvalSymType {
+		return "", false
+	}
+	return v.sym, true
+}
+
+// List returns the elements of an S-expression or Q-expression
+func (x Value) List() ([]Value, bool) {
+	v := x.lval()
+	if v.ltype != lvalSexprType && v.ltype != lvalQexprType {
+		return nil, false
+	}
+	list := make([]Value, len(v.cells))
+	for i, cell := range v.cells {
+		list[i] = Value{cell}
+	}
+	return list, true
+}
+
+// Entries returns the entries of a map, ordered as they are printed
+func (x Value) Entries() ([]Entry, bool) {
+	v := x.lval()
+	if v.ltype != lvalMapType {
+		return nil, false
+	}
+	entries := v.lvalMapEntries()
+	list := make([]Entry, len(entries))
+	for i, entry := range entries {
+		list[i] = Entry{Value{entry.key}, Value{entry.val}}
+	}
+	return list, true
+}
+
+// Err returns an error value as an *EvalError, or nil if x is not an error
+func (x Value) Err() error {
+	v := x.lval()
+	if v.ltype != lvalErrType {
+		return nil
+	}
+	return lvalError(v)
+}
+
+// Equal reports whether x and y are equal, as == would
+func (x Value) Equal(y Value) bool {
+	return lvalEq(x.lval(), y.lval())
+}
+
+// NewInt returns an integer Value
+func NewInt(n int64) Value {
+	return Value{lvalNum(n)}
+}
+
+// NewBigInt returns an integer Value of any size
+func NewBigInt(n *big.Int) Value {
+	return Value{lvalBigNum(new(big.Int).Set(n))}
+}
+
+// NewRat returns a rational Value, which is an integer if r is whole
+func NewRat(r *big.Rat) Value {
+	return Value{lvalRat(new(big.Rat).Set(r))}
+}
+
+// NewFloat returns a floating-point Value
+func NewFloat(f float64) Value {
+	return Value{lvalFloat(f)}
+}
+
+// NewBool returns a boolean Value
+func NewBool(b bool) Value {
+	return Value{lvalBool(b)}
+}
+
+// NewString returns a string Value
+func NewString(s string) Value {
+	return Value{lvalStr(s)}
+}
+
+// NewSymbol returns a symbol Value
+func NewSymbol(name string) Value {
+	return Value{lvalSym(name)}
+}
+
+// NewError returns an error Value with the given message
+func NewError(msg string) Value {
+	return Value{lvalErr("%s", msg)}
+}
+
+// NewList returns a Q-expression of the given values
+func NewList(values ...Value) Value {
+	return Value{lvalValues(lvalQexpr(), values)}
+}
+
+// NewSExpr returns an S-expression of the given values, which evaluates as
+// a call when passed to Env.Eval
+func NewSExpr(values ...Value) Value {
+	return Value{lvalValues(lvalSexpr(), values)}
+}
+
+// NewMap returns a map of the given entries. Later entries replace earlier
+// ones with an equal key.
+func NewMap(entries ...Entry) (Value, error) {
+	m := lvalMap()
+	for _, entry := range entries {
+		if x := m.lvalMapPut(entry.Key.lval(), entry.Value.lval()); x.ltype == lvalErrType {
+			return Value{}, errors.New(x.err)
+		}
+	}
+	return Value{m}, nil
+}
+
+// lvalValues adds copies of values to the list v
+func lvalValues(v *lval, values []Value) *lval {
+	for _, x := range values {
+		v = lvalAdd(v, lvalCopy(x.lval()))
+	}
+	return v
+}
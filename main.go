@@ -2,38 +2,159 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/sunzenshen/go-build-your-own-lisp/lineedit"
 	"github.com/sunzenshen/go-build-your-own-lisp/lispy"
 )
 
+const usage = `Usage: lispy [flags] [script [args...]]
+       lispy [flags] -e expr [args...]
+
+Runs script with argv bound to a list of args, or the program on stdin when
+script is -. Without a script or -e, starts the interactive interpreter, or
+runs the program on stdin when it is not a terminal.
+
+Flags:
+`
+
 func main() {
-	// Version and Exit Information
-	fmt.Println("Lispy Version 0.0.0.0.4")
-	fmt.Print("Press Ctrl+c to Exit\n\n")
-	// For reading lines of user input
-	scanner := bufio.NewScanner(os.Stdin)
-
-	l := lispy.InitLispy()
-	defer lispy.CleanLispy(l)
-
-	// Load standard library
-	l.LoadFiles([]string{"lispy/prelude.lspy"})
-
-	// Supplied with a list of files
-	if len(os.Args) > 1 {
-		fmt.Println("Files passed into Lispy interpreter")
-		l.LoadFiles(os.Args[1:])
-	}
-
-	for {
-		// Prompt
-		fmt.Print("lispy> ")
-		// Read a line of user input
-		scanner.Scan()
-		input := scanner.Text()
-		// Echo input back to user
-		l.ReadEvalPrint(input)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// exprList collects each -e flag
+type exprList []string
+
+func (e *exprList) String() string {
+	return strings.Join(*e, " ")
+}
+
+func (e *exprList) Set(expr string) error {
+	*e = append(*e, expr)
+	return nil
+}
+
+// run runs the interpreter with the command-line arguments args, and
+// returns its exit status
+func run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lispy", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	var exprs exprList
+	flags.Var(&exprs, "e", "evaluate `expr` and print its value, instead of running a script (repeatable)")
+	interactive := flags.Bool("i", false, "start the interactive interpreter after running the script or -e")
+	noPrelude := flags.Bool("no-prelude", false, "do not load the standard library")
+	prelude := flags.String("prelude", "", "load the standard library from `path` instead of the built-in one")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	// Arguments follow the script, or -e when there is no script
+	script, argv := "", flags.Args()
+	if len(argv) > 0 && len(exprs) == 0 {
+		script, argv = argv[0], argv[1:]
+	}
+	// Programs can be piped in as well as typed
+	if script == "" && len(exprs) == 0 && !*interactive && !lineedit.IsTerminal(stdin) {
+		script = "-"
+	}
+
+	// For reading lines of user input, shared with read-line
+	reader := bufio.NewReader(stdin)
+	r, err := newREPL(stdin, reader, stdout, stderr, func(l *lispy.Lispy) error {
+		list := make([]lispy.Value, len(argv))
+		for i, arg := range argv {
+			list[i] = lispy.NewString(arg)
+		}
+		l.Env().Def("argv", lispy.NewList(list...))
+		// Load standard library
+		if *prelude != "" {
+			return l.LoadFile(*prelude)
+		}
+		if !*noPrelude {
+			return l.LoadPrelude()
+		}
+		return nil
+	})
+	defer func() { lispy.CleanLispy(r.l) }()
+	if err != nil {
+		return exitStatus(stderr, err)
+	}
+
+	for _, expr := range exprs {
+		v, err := r.l.Eval(context.Background(), expr)
+		if err != nil {
+			return exitStatus(stderr, err)
+		}
+		if v.Kind() != lispy.SExprKind {
+			fmt.Fprintln(stdout, v)
+		}
+	}
+	switch script {
+	case "":
+	case "-":
+		src, err := io.ReadAll(reader)
+		if err == nil {
+			err = r.l.LoadSource("<stdin>", string(src))
+		}
+		if err != nil {
+			return exitStatus(stderr, err)
+		}
+	default:
+		if err := r.l.LoadFile(script); err != nil {
+			return exitStatus(stderr, err)
+		}
+	}
+
+	if script == "" && len(exprs) == 0 || *interactive {
+		// Version and Exit Information
+		fmt.Fprintln(stdout, "Lispy Version 0.0.0.0.4")
+		fmt.Fprint(stdout, "Press Ctrl+d to Exit, or Ctrl+c to stop an evaluation\n")
+		fmt.Fprint(stdout, "Enter :help for the commands of the interpreter\n\n")
+		return r.run()
+	}
+	return 0
+}
+
+// exitStatus returns the status to exit with after err, which is printed
+// unless it came from calling exit
+func exitStatus(w io.Writer, err error) int {
+	var exit *lispy.ExitError
+	if errors.As(err, &exit) {
+		return exit.Code
+	}
+	printError(w, err)
+	return 1
+}
+
+// printError prints err as the REPL prints errors, followed by the calls an
+// evaluation error was raised through
+func printError(w io.Writer, err error) {
+	var perr *lispy.ParseError
+	var eerr *lispy.EvalError
+	switch {
+	case errors.As(err, &perr):
+		fmt.Fprintln(w, perr)
+	case errors.As(err, &eerr):
+		if eerr.File != "" {
+			fmt.Fprintf(w, "%s:%d:%d: ", eerr.File, eerr.Line, eerr.Col)
+		}
+		fmt.Fprintf(w, "Error: %s\n", eerr.Msg)
+		for _, frame := range eerr.Trace {
+			fmt.Fprintln(w, "  "+frame)
+		}
+	default:
+		fmt.Fprintf(w, "Error: %s\n", err)
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunArgs(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lspy")
+	if err := os.WriteFile(script, []byte("(print (len argv) argv)\n(error \"bad\")\n(print \"not reached\")\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := filepath.Join(dir, "tool.lspy")
+	if err := os.WriteFile(tool, []byte("#!/usr/bin/env lispy\n(if (== argv nil) {exit 2} {print argv})\n(exit 3)\n(print \"not reached\")\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prelude := filepath.Join(dir, "prelude.lspy")
+	if err := os.WriteFile(prelude, []byte("(def {answer} 42)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args    []string
+		stdin   string
+		want    string
+		wantErr string
+		code    int
+	}{
+		{[]string{"-e", "+ 1 2", "-e", "print \"x\""}, "", "3\n\"x\" \n", "", 0},
+		{[]string{"-e", "head {}"}, "", "", "<eval>:1:1: Error: Function 'head' passed {}!\n  at head (<eval>:1:1)\n", 1},
+		{[]string{"-e", "(+ 1"}, "", "", "<eval>:1:5: error: expected ')' to close '(' at 1:1\n", 1},
+		{[]string{"-e", "len argv", "-e", "argv", "x", "y"}, "", "2\n{\"x\" \"y\"}\n", "", 0},
+		{[]string{script, "a", "b"}, "", "2 {\"a\" \"b\"} \n", script + ":2:1: Error: bad\n  at error (" + script + ":2:1)\n", 1},
+		{[]string{filepath.Join(dir, "missing.lspy")}, "", "", "Error: open " + filepath.Join(dir, "missing.lspy") + ": no such file or directory\n", 1},
+		// Scripts run through a #! line
+		{[]string{tool, "-v", "name"}, "", "{\"-v\" \"name\"} \n", "", 3},
+		{[]string{tool}, "", "", "", 2},
+		{[]string{"-e", "exit 0", "-e", "print 1"}, "", "", "", 0},
+		{[]string{"-e", "exit 256"}, "", "", "<eval>:1:1: Error: exit passed 256, expected a status from 0 to 255\n  at exit (<eval>:1:1)\n", 1},
+		// Programs on stdin
+		{nil, "(print (fib 10))\n(print argv)", "55 \n{} \n", "", 0},
+		{[]string{"-", "arg"}, "(print (fib 10))\n(print argv)", "55 \n{\"arg\"} \n", "", 0},
+		{nil, "(print 1)\n(print", "", "<stdin>:2:7: error: expected ')' to close '(' at 2:1\n", 1},
+		// The standard library
+		{[]string{"--no-prelude", "-e", "fib 10"}, "", "", "<eval>:1:1: Error: Unbound Symbol: 'fib'\n", 1},
+		{[]string{"--prelude", prelude, "-e", "answer"}, "", "42\n", "", 0},
+		{[]string{"--prelude", filepath.Join(dir, "missing.lspy")}, "", "", "Error: open " + filepath.Join(dir, "missing.lspy") + ": no such file or directory\n", 1},
+		// Interactive after the script
+		{[]string{"-i", "-e", "def {x} 5"}, "+ x 1\n", "Lispy Version 0.0.0.0.4\nPress Ctrl+d to Exit, or Ctrl+c to stop an evaluation\nEnter :help for the commands of the interpreter\n\nlispy> 6\nlispy> \n", "", 0},
+	}
+
+	for _, c := range cases {
+		stdin := filepath.Join(dir, "stdin")
+		if err := os.WriteFile(stdin, []byte(c.stdin), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		code := run(c.args, f, &stdout, &stderr)
+		f.Close()
+		if code != c.code || stdout.String() != c.want || stderr.String() != c.wantErr {
+			t.Errorf("Run args: %q returned: %d, %q and %q, actually expected: %d, %q and %q",
+				c.args, code, stdout.String(), stderr.String(), c.code, c.want, c.wantErr)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-x"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("Run of an unknown flag returned: %d, actually expected: 2", code)
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("Usage: lispy")) {
+		t.Errorf("Run of an unknown flag wrote: %q, actually expected the usage", stderr.String())
+	}
+}
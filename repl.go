@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunzenshen/go-build-your-own-lisp/lineedit"
+	"github.com/sunzenshen/go-build-your-own-lisp/lispy"
+)
+
+// repl reads expressions from the user and prints what they evaluate to
+type repl struct {
+	l      lispy.Lispy
+	ed     *lineedit.Editor
+	reader *bufio.Reader
+	out    io.Writer
+	errOut io.Writer
+	// setup loads an interpreter, when starting and on :reset
+	setup func(l *lispy.Lispy) error
+}
+
+// newREPL returns a repl reading from reader, which is shared with
+// read-line, and editing lines when the input is a terminal. The error is
+// that of setting up its interpreter.
+func newREPL(term *os.File, reader *bufio.Reader, out, errOut io.Writer, setup func(l *lispy.Lispy) error) (*repl, error) {
+	r := &repl{reader: reader, out: out, errOut: errOut, setup: setup}
+	err := r.reset()
+	// Line editing, with history kept across sessions and completion of
+	// global symbols
+	r.ed = lineedit.New(term, reader, out)
+	if home, err := os.UserHomeDir(); err == nil && r.ed.Terminal() {
+		if err := r.ed.LoadHistory(filepath.Join(home, ".lispy_history")); err != nil {
+			fmt.Fprintln(errOut, "Could not load history:", err)
+		}
+	}
+	r.ed.Complete = r.names
+	return r, err
+}
+
+// reset replaces the interpreter with a freshly loaded one
+func (r *repl) reset() error {
+	if r.l != (lispy.Lispy{}) {
+		lispy.CleanLispy(r.l)
+	}
+	r.l = lispy.InitLispy()
+	r.l.SetStdin(r.reader)
+	r.l.SetStdout(r.out)
+	r.l.SetStderr(r.errOut)
+	return r.setup(&r.l)
+}
+
+// names lists the global symbols, and the commands when word starts with a
+// colon, that begin with word
+func (r *repl) names(word string) []string {
+	var all []string
+	if strings.HasPrefix(word, ":") {
+		for _, c := range commands {
+			all = append(all, c.name)
+		}
+	} else {
+		all = r.l.Env().Names()
+	}
+	var names []string
+	for _, name := range all {
+		if strings.HasPrefix(name, word) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// run reads and evaluates input until it ends, :quit is entered or exit is
+// called, and returns the status passed to exit
+func (r *repl) run() int {
+	for {
+		// Read lines of user input until they make up whole expressions
+		input, err := r.readInput()
+		if err == lineedit.ErrInterrupt {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(r.out)
+			return 0
+		}
+		// Ctrl+c stops the evaluation, rather than the interpreter
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		if strings.HasPrefix(strings.TrimSpace(input), ":") {
+			quit := r.command(ctx, input)
+			stop()
+			if quit {
+				return 0
+			}
+			continue
+		}
+		// Echo input back to user
+		err = r.l.ReadEvalPrintContext(ctx, input)
+		stop()
+		var exit *lispy.ExitError
+		if errors.As(err, &exit) {
+			return exit.Code
+		}
+	}
+}
+
+// readInput reads a line of input, followed by further lines while strings,
+// brackets or braces are left open. It returns io.EOF when the input ended
+// before anything was read.
+func (r *repl) readInput() (string, error) {
+	var lines []string
+	prompt := "lispy> "
+	for {
+		line, err := r.ed.ReadLine(prompt)
+		if err == io.EOF && len(lines) > 0 {
+			// Evaluate an unfinished expression, to report what is missing
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		r.ed.AddHistory(line)
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		// The expression passed to a command can also span lines
+		if !lispy.Incomplete(strings.TrimPrefix(strings.TrimSpace(input), ":")) {
+			return input, nil
+		}
+		// Continuation prompt
+		prompt = "  ...> "
+	}
+}
+
+// A command of the REPL, entered after a colon
+type command struct {
+	name string
+	args string
+	help string
+	run  func(r *repl, ctx context.Context, arg string) bool
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{":env", "", "List the global symbols and the types of their values", (*repl).env},
+		{":type", "expr", "Print the type of what expr evaluates to", (*repl).typeOf},
+		{":ast", "expr", "Print the syntax tree expr is read into", (*repl).ast},
+		{":load", "file", "Evaluate the expressions of a file", (*repl).load},
+		{":reset", "", "Start over with a new interpreter", (*repl).resetCommand},
+		{":help", "", "List the commands", (*repl).help},
+		{":quit", "", "Leave the interpreter", (*repl).quit},
+	}
+}
+
+// command runs the command in input, and reports whether to quit
+func (r *repl) command(ctx context.Context, input string) bool {
+	input = strings.TrimSpace(input)
+	name, arg := input, ""
+	if i := strings.IndexAny(input, " \t\n"); i >= 0 {
+		name, arg = input[:i], strings.TrimSpace(input[i:])
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if c.args != "" && arg == "" {
+			fmt.Fprintf(r.errOut, "Command %s expects %s\n", name, c.args)
+			return false
+		}
+		if c.args == "" && arg != "" {
+			fmt.Fprintf(r.errOut, "Command %s expects nothing after it\n", name)
+			return false
+		}
+		return c.run(r, ctx, arg)
+	}
+	fmt.Fprintf(r.errOut, "Unknown command %s, enter :help for a list\n", name)
+	return false
+}
+
+func (r *repl) env(ctx context.Context, arg string) bool {
+	names := r.l.Env().Names()
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for _, name := range names {
+		v, _ := r.l.Env().Get(name)
+		fmt.Fprintf(r.out, "%-*s  %s\n", width, name, v.Kind())
+	}
+	return false
+}
+
+func (r *repl) typeOf(ctx context.Context, arg string) bool {
+	v, err := r.l.Eval(ctx, arg)
+	if err != nil {
+		printError(r.errOut, err)
+		return false
+	}
+	fmt.Fprintln(r.out, v.Kind())
+	return false
+}
+
+func (r *repl) ast(ctx context.Context, arg string) bool {
+	r.l.PrintAst(arg)
+	return false
+}
+
+func (r *repl) load(ctx context.Context, arg string) bool {
+	// The path may be quoted, as it is for load
+	path := arg
+	if len(path) >= 2 && path[0] == '"' && path[len(path)-1] == '"' {
+		path = path[1 : len(path)-1]
+	}
+	if err := r.l.LoadFileContext(ctx, path); err != nil {
+		printError(r.errOut, err)
+	}
+	return false
+}
+
+func (r *repl) resetCommand(ctx context.Context, arg string) bool {
+	if err := r.reset(); err != nil {
+		printError(r.errOut, err)
+	}
+	return false
+}
+
+func (r *repl) help(ctx context.Context, arg string) bool {
+	for _, c := range commands {
+		fmt.Fprintf(r.out, "%-12s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+	return false
+}
+
+func (r *repl) quit(ctx context.Context, arg string) bool {
+	return true
+}
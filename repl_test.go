@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sunzenshen/go-build-your-own-lisp/lispy"
+)
+
+func TestCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "square.lspy")
+	if err := os.WriteFile(path, []byte("(def {square} (\\ {x} {* x x}))"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{":type (+ 1 2)", "Number\n", ""},
+		{":type {1\n2}", "Q-Expression\n", ""},
+		{":type \"text\"", "String\n", ""},
+		{":type double", "Function\n", ""},
+		{":type (head {})", "", "<eval>:1:1: Error: Function 'head' passed {}!\n  at head (<eval>:1:1)\n"},
+		{":type", "", "Command :type expects expr\n"},
+		{":ast 1", "> \n  number:1:1 '1'\n", ""},
+		{":load " + path, "", ""},
+		{"square 4", "16\n", ""},
+		{":load \"" + path + "\"", "", ""},
+		{":load missing.lspy", "", "Error: open missing.lspy: no such file or directory\n"},
+		{":reset", "", ""},
+		{"square 4", "", "<stdin>:1:1: Error: Unbound Symbol: 'square'\n"},
+		{"double 4", "8\n", ""},
+		{":env x", "", "Command :env expects nothing after it\n"},
+		{":nope", "", "Unknown command :nope, enter :help for a list\n"},
+	}
+
+	var out, errOut bytes.Buffer
+	in := bufio.NewReader(strings.NewReader(""))
+	r, _ := newREPL(nil, in, &out, &errOut, func(l *lispy.Lispy) error {
+		return l.LoadSource("<setup>", "(def {double} (\\ {x} {* 2 x}))")
+	})
+	for _, c := range cases {
+		out.Reset()
+		errOut.Reset()
+		if strings.HasPrefix(c.input, ":") {
+			if r.command(context.Background(), c.input) {
+				t.Errorf("Command input: \"%s\" quit the interpreter", c.input)
+			}
+		} else {
+			r.l.ReadEvalPrint(c.input)
+		}
+		if out.String() != c.want || errOut.String() != c.wantErr {
+			t.Errorf("Command input: \"%s\" wrote: %q and %q, actually expected: %q and %q",
+				c.input, out.String(), errOut.String(), c.want, c.wantErr)
+		}
+	}
+	if !r.command(context.Background(), ":quit") {
+		t.Errorf("Command input: \":quit\" did not quit the interpreter")
+	}
+}
+
+func TestCommandLoadCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.lspy")
+	if err := os.WriteFile(path, []byte("(def {loop} (\\ {n} {loop n}))\n(loop 0)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	in := bufio.NewReader(strings.NewReader(""))
+	r, _ := newREPL(nil, in, &out, &errOut, func(l *lispy.Lispy) error { return nil })
+	// As Ctrl+c does, cancelling the context stops a file that never ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r.command(ctx, ":load "+path)
+	if !strings.Contains(errOut.String(), "Evaluation cancelled") {
+		t.Errorf("Command input: \":load\" of an endless file wrote: %q, actually expected it to be cancelled", errOut.String())
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	var out bytes.Buffer
+	in := bufio.NewReader(strings.NewReader(""))
+	r, _ := newREPL(nil, in, &out, &out, func(l *lispy.Lispy) error {
+		return l.LoadSource("<setup>", "(def {zz-name zz-text} 1 \"one\")")
+	})
+	r.command(context.Background(), ":env")
+	kinds := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		kinds[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	for name, want := range map[string]string{"zz-name": "Number", "zz-text": "String", "\\": "Function"} {
+		if kinds[name] != want {
+			t.Errorf("Command :env listed %s as: %q, actually expected: %q", name, kinds[name], want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out, errOut bytes.Buffer
+	in := bufio.NewReader(strings.NewReader("(+ 1\n2)\n:type 1\n:quit\n(+ 3 4)\n"))
+	r, _ := newREPL(nil, in, &out, &errOut, func(l *lispy.Lispy) error { return nil })
+	r.run()
+	want := "lispy>   ...> 3\nlispy> Number\nlispy> "
+	if out.String() != want || errOut.String() != "" {
+		t.Errorf("Run wrote: %q and %q, actually expected: %q", out.String(), errOut.String(), want)
+	}
+}
+
+func TestRunExit(t *testing.T) {
+	var out, errOut bytes.Buffer
+	in := bufio.NewReader(strings.NewReader("print 1\nexit 4\nprint 2\n"))
+	r, _ := newREPL(nil, in, &out, &errOut, func(l *lispy.Lispy) error { return nil })
+	if code := r.run(); code != 4 {
+		t.Errorf("Run returned: %d, actually expected the status passed to exit: 4", code)
+	}
+	want := "lispy> 1 \n()\nlispy> "
+	if out.String() != want || errOut.String() != "" {
+		t.Errorf("Run wrote: %q and %q, actually expected: %q", out.String(), errOut.String(), want)
+	}
+}